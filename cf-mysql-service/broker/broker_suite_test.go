@@ -7,5 +7,5 @@ import (
 )
 
 func TestService(t *testing.T) {
-	helpers.PrepareAndRunTests("Broker", t, true)
+	helpers.PrepareAndRunTests("Broker", t, true, helpers.RequireCF, helpers.RequireService, helpers.RequireBroker)
 }
@@ -0,0 +1,43 @@
+package broker_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
+)
+
+var _ = helpers.DescribeEachService("P-MySQL Service broker catalog", func(service helpers.Service) {
+	It("advertises exactly the configured plans, in the broker and in Cloud Controller", func() {
+		if helpers.TestConfig.BrokerUsername == "" || helpers.TestConfig.BrokerPassword == "" {
+			Skip("Skipping as broker_username and broker_password are not set")
+		}
+
+		catalog, err := helpers.NewBrokerClient(helpers.TestConfig).Catalog()
+		Expect(err).NotTo(HaveOccurred())
+
+		brokerService, found := catalog.Service(service.Name)
+		Expect(found).To(BeTrue(), "The broker catalog does not offer %s", service.Name)
+
+		api, err := helpers.NewCFAPIClientForAdmin(helpers.TestConfig.CFConfig)
+		Expect(err).NotTo(HaveOccurred())
+
+		offerings, err := api.ServiceOfferings(service.Name)
+		Expect(err).NotTo(HaveOccurred())
+
+		// other brokers may offer a service of the same name
+		var ccPlans []helpers.CFServicePlan
+		for _, offering := range offerings {
+			if offering.BrokerCatalog.ID != brokerService.ID {
+				continue
+			}
+
+			plans, err := api.ServicePlans(offering.GUID)
+			Expect(err).NotTo(HaveOccurred())
+			ccPlans = append(ccPlans, plans...)
+		}
+
+		diffs := helpers.DiffCatalog(service, brokerService, ccPlans)
+		Expect(diffs).To(BeEmpty(), "The catalog differs from the config:\n%s", helpers.FormatPlanDiffs(diffs))
+	})
+})
@@ -7,5 +7,5 @@ import (
 )
 
 func TestFailover(t *testing.T) {
-	helpers.PrepareAndRunTests("Failover", t, true)
+	helpers.PrepareAndRunTests("Failover", t, true, helpers.RequireCF, helpers.RequireService, helpers.RequireProxy, helpers.RequireBOSH)
 }
@@ -1,26 +1,14 @@
 package failover_test
 
 import (
-	"crypto/tls"
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
-	. "github.com/onsi/gomega/gexec"
-
-	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
-
-	boshuaa "github.com/cloudfoundry/bosh-cli/uaa"
-	boshdir "github.com/cloudfoundry/bosh-cli/director"
-	boshlog "github.com/cloudfoundry/bosh-utils/logger"
-
-	"github.com/cloudfoundry-incubator/cf-test-helpers/generator"
 
 	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers/workflow"
 	"strings"
 )
 
@@ -34,57 +22,8 @@ const (
 	sinatraPath = "../../assets/sinatra_app"
 )
 
-func buildUAA() (boshuaa.UAA, error) {
-	logger := boshlog.NewLogger(boshlog.LevelError)
-	factory := boshuaa.NewFactory(logger)
-
-	// Build a UAA config from a URL.
-	// HTTPS is required and certificates are always verified.
-	config, err := boshuaa.NewConfigFromURL(fmt.Sprintf("https://%s:8443", helpers.TestConfig.BOSH.URL))
-	if err != nil {
-		return nil, err
-	}
-
-	// Set client credentials for authentication.
-	// Machine level access should typically use a client instead of a particular user.
-	config.Client = helpers.TestConfig.BOSH.Client
-	config.ClientSecret = helpers.TestConfig.BOSH.ClientSecret
-
-	// Configure trusted CA certificates.
-	// If nothing is provided default system certificates are used.
-	config.CACert = helpers.TestConfig.BOSH.CACert
-
-	return factory.New(config)
-}
-
-func buildDirector(uaa boshuaa.UAA) (boshdir.Director, error) {
-	logger := boshlog.NewLogger(boshlog.LevelError)
-	factory := boshdir.NewFactory(logger)
-
-	// Build a Director config from address-like string.
-	// HTTPS is required and certificates are always verified.
-	config, err := boshdir.NewConfigFromURL(helpers.TestConfig.BOSH.URL)
-	if err != nil {
-		return nil, err
-	}
-
-	// Configure custom trusted CA certificates.
-	// If nothing is provided default system certificates are used.
-	config.CACert = helpers.TestConfig.BOSH.CACert
-
-	// Allow Director to fetch UAA tokens when necessary.
-	config.TokenFunc = boshuaa.NewClientTokenSession(uaa).TokenFunc
-
-	return factory.New(config, boshdir.NewNoopTaskReporter(), boshdir.NewNoopFileReporter())
-}
-
 func deleteMysqlVM(host string) error {
-	uaa, err := buildUAA()
-	if err != nil {
-		return err
-	}
-
-	director, err := buildDirector(uaa)
+	director, err := helpers.NewBOSHDirector(helpers.TestConfig.BOSH)
 	if err != nil {
 		return err
 	}
@@ -118,59 +57,26 @@ func deleteMysqlVM(host string) error {
 }
 
 func activeProxyBackend() (string, error) {
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-
-	client := &http.Client{Transport: tr}
-
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/v0/cluster", helpers.TestConfig.Proxy.DashboardUrls[0]), nil)
-	if err != nil {
-		return "", err
-	}
-
-	req.SetBasicAuth(helpers.TestConfig.Proxy.APIUsername, helpers.TestConfig.Proxy.APIPassword)
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
-	}
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
-
-	var cluster struct {
-		ActiveBackend struct {
-			Host string `json:"host"`
-		} `json:"activeBackend`
-	}
-
-	if err := json.Unmarshal(body, &cluster); err != nil {
-		return "", err
-	}
-
-	return cluster.ActiveBackend.Host, nil
+	backend, err := helpers.NewProxyAPIClient(helpers.TestConfig.Proxy, helpers.TestConfig.Proxy.DashboardUrls[0]).ActiveBackend()
+	return backend.Host, err
 }
 
 var _ = Describe("CF MySQL Failover", func() {
 	It("write/read data before and after a partition of mysql node", func() {
 		var oldBackend string
 
-		serviceInstanceName := generator.PrefixedRandomName("failover", "instance")
-		appName := generator.PrefixedRandomName("failover", "app")
+		serviceInstanceName := helpers.RandomName("FAILOVER-INSTANCE")
 
-		var appClient = helpers.NewSinatraAppClient(helpers.TestConfig.AppURI(appName), serviceInstanceName, helpers.TestConfig.CFConfig.SkipSSLValidation)
+		app, err := workflow.PushApp(helpers.RandomName("FAILOVER-APP"), workflow.AppOptions{Memory: "256M", Path: sinatraPath, Buildpack: "ruby_buildpack"})
+		Expect(err).NotTo(HaveOccurred())
 
-		Expect(cf.Cf("push", appName, "-m", "256M", "-p", sinatraPath, "-b", "ruby_buildpack", "--no-start").
-			Wait(helpers.TestContext.LongTimeout())).
-			To(Exit(0))
+		var appClient = helpers.NewSinatraAppClient(app.URI, serviceInstanceName, helpers.TestConfig.CFConfig.SkipSSLValidation)
 
-		Expect(cf.Cf("create-service", helpers.TestConfig.ServiceName, planName, serviceInstanceName).Wait(helpers.TestContext.LongTimeout())).To(Exit(0))
-		Expect(cf.Cf("bind-service", appName, serviceInstanceName).Wait(helpers.TestContext.LongTimeout())).To(Exit(0))
-		Expect(cf.Cf("start", appName).Wait(helpers.TestContext.LongTimeout())).To(Exit(0))
-		err := appClient.Ping()
+		instance, err := workflow.CreateInstance(helpers.TestConfig.AllServices()[0].Name, planName, serviceInstanceName)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = workflow.Bind(app, instance)
 		Expect(err).NotTo(HaveOccurred())
+		Expect(workflow.StartAndWait(app, appClient)).To(Succeed())
 
 		msg, err := appClient.Set(firstKey, firstValue)
 		Expect(msg).To(ContainSubstring(firstValue))
@@ -180,20 +86,20 @@ var _ = Describe("CF MySQL Failover", func() {
 		Expect(msg).To(ContainSubstring(firstValue))
 		Expect(err).NotTo(HaveOccurred())
 
-		By("querying the proxy for the current mysql backend", func() {
+		helpers.Step("querying the proxy for the current mysql backend", func() {
 			var err error
 
 			oldBackend, err = activeProxyBackend()
 			Expect(err).NotTo(HaveOccurred())
 		})
 
-		By("Take down the active mysql node", func() {
+		helpers.Step("Take down the active mysql node", func() {
 			err := deleteMysqlVM(oldBackend)
 			Expect(err).NotTo(HaveOccurred())
 
 		})
 
-		By("poll the proxy for a backend change", func() {
+		helpers.Step("poll the proxy for a backend change", func() {
 			Eventually(func() bool {
 				backend, err := activeProxyBackend()
 				Expect(err).NotTo(HaveOccurred())
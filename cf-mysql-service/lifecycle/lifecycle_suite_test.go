@@ -7,5 +7,5 @@ import (
 )
 
 func TestService(t *testing.T) {
-	helpers.PrepareAndRunTests("Lifecycle tests", t, true)
+	helpers.PrepareAndRunTests("Lifecycle tests", t, true, helpers.RequireCF, helpers.RequireService)
 }
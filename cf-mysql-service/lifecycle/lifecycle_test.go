@@ -5,50 +5,43 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
-	. "github.com/onsi/gomega/gexec"
 
 	"os"
 
 	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
-	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
-	"github.com/cloudfoundry-incubator/cf-test-helpers/generator"
-	"github.com/cloudfoundry-incubator/cf-test-helpers/workflowhelpers"
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers/workflow"
 )
 
-var _ = Describe("P-MySQL Lifecycle Tests", func() {
+var _ = helpers.DescribeEachService("P-MySQL Lifecycle Tests", func(service helpers.Service) {
 	var sinatraPath = "../../assets/sinatra_app"
 	var springPath = "../../assets/cipher_finder"
 
-	var enableServiceAccessToOrg func(string, string)
-	var createBindAndStartApp func(string, string, string, string, helpers.Pinger)
-	var createServiceInstanceAndKey func(string, string, string, string, string) *Session
-	var cleanupServiceInstance func(string, string)
-	var cleanupServiceInstanceAndKey func(string, string)
+	var createBindAndStartApp func(workflow.App, string, string, helpers.Pinger)
+	var createServiceInstanceAndKey func(string, string, string, string, string) error
+	var expectServiceKey func(string, string)
 
-	It("Lists all public plans in cf marketplace", func() {
-		marketplaceCmd := cf.Cf("m").Wait(helpers.TestContext.LongTimeout())
-		Expect(marketplaceCmd).To(Exit(0))
+	It("Lists all public plans in the marketplace", func() {
+		marketplacePlans, err := visiblePlanNames(service.Name)
+		Expect(err).NotTo(HaveOccurred())
 
-		marketplaceOutput := marketplaceCmd.Out.Contents()
-		for _, plan := range helpers.TestConfig.Plans {
+		for _, plan := range service.Plans {
 			if plan.Private == false {
-				Expect(marketplaceOutput).To(MatchRegexp("%v.*%v", helpers.TestConfig.ServiceName, plan.Name))
+				Expect(marketplacePlans).To(ContainElement(plan.Name))
 			}
 		}
 	})
 
-	It("Does not list any private plans in cf marketplace", func() {
+	It("Does not list any private plans in the marketplace", func() {
 		if helpers.TestConfig.CFConfig.UseExistingOrganization {
 			Skip("Skipping private plan test due to use of existing org")
 		}
 
-		marketplaceCmd := cf.Cf("m").Wait(helpers.TestContext.LongTimeout())
-		Expect(marketplaceCmd).To(Exit(0))
+		marketplacePlans, err := visiblePlanNames(service.Name)
+		Expect(err).NotTo(HaveOccurred())
 
-		marketplaceOutput := marketplaceCmd.Out.Contents()
-		for _, plan := range helpers.TestConfig.Plans {
+		for _, plan := range service.Plans {
 			if plan.Private == true {
-				Expect(marketplaceOutput).ToNot(MatchRegexp("%v.*%v", helpers.TestConfig.ServiceName, plan.Name))
+				Expect(marketplacePlans).ToNot(ContainElement(plan.Name))
 			}
 		}
 	})
@@ -57,49 +50,47 @@ var _ = Describe("P-MySQL Lifecycle Tests", func() {
 		var plan helpers.Plan
 
 		BeforeEach(func() {
-			if len(helpers.TestConfig.Plans) > 0 {
-				plan = helpers.TestConfig.Plans[0]
+			if len(service.Plans) > 0 {
+				plan = service.Plans[0]
 			} else {
 				Skip("Skipping due to lack of plans.")
 			}
 
-			enableServiceAccessToOrg(helpers.TestConfig.ServiceName, helpers.TestContext.RegularUserContext().Org)
+			Expect(workflow.EnableServiceAccess(service.Name, helpers.TestContext.RegularUserContext().Org)).To(Succeed())
 		})
 
 		Describe("When pushing an app", func() {
 			var appName, serviceInstanceName string
-			var sinatraAppClient helpers.SinatraAppClient
-			var cipherFinderAppClient helpers.CipherFinderClient
 
 			BeforeEach(func() {
-				appName = generator.PrefixedRandomName("lifecycle", "app")
-				serviceInstanceName = generator.PrefixedRandomName("lifecycle", "instance")
-
-				sinatraAppClient = helpers.NewSinatraAppClient(helpers.TestConfig.AppURI(appName), serviceInstanceName, helpers.TestConfig.CFConfig.SkipSSLValidation)
-				cipherFinderAppClient = helpers.NewCipherFinderClient(helpers.TestConfig.AppURI(appName), helpers.TestConfig.CFConfig.SkipSSLValidation)
+				appName = helpers.RandomName("LIFECYCLE-APP")
+				serviceInstanceName = helpers.RandomName("LIFECYCLE-INSTANCE")
 			})
 
-			AfterEach(func() {
-				cleanupServiceInstance(appName, serviceInstanceName)
-			})
+			for _, lifecyclePlan := range service.Plans {
+				lifecyclePlan := lifecyclePlan
 
-			It("Allows users to create, bind, write to, read from, unbind, and destroy a service instance for the each plan", func() {
-				Expect(cf.Cf("push", appName, "-m", "256M", "-p", sinatraPath, "-b", "ruby_buildpack", "-d", helpers.TestConfig.CFConfig.AppsDomain, "--no-start").
-					Wait(helpers.TestContext.LongTimeout())).
-					To(Exit(0))
+				It(fmt.Sprintf("Allows users to create, bind, write to, read from, unbind, and destroy a service instance of the %s plan", lifecyclePlan.Name), func() {
+					app, err := workflow.PushApp(appName, workflow.AppOptions{Memory: "256M", Path: sinatraPath, Buildpack: "ruby_buildpack"})
+					Expect(err).NotTo(HaveOccurred())
 
-				createBindAndStartApp(helpers.TestConfig.ServiceName, plan.Name, serviceInstanceName, appName, sinatraAppClient)
+					sinatraAppClient := helpers.NewSinatraAppClient(app.URI, serviceInstanceName, helpers.TestConfig.CFConfig.SkipSSLValidation)
+					createBindAndStartApp(app, lifecyclePlan.Name, serviceInstanceName, sinatraAppClient)
 
-				fmt.Printf("\n*** Posting to app\n")
-				msg, err := sinatraAppClient.Set("mykey", "myvalue")
-				Expect(err).NotTo(HaveOccurred())
-				Expect(msg).To(ContainSubstring("myvalue"))
+					fmt.Printf("\n*** Posting to app\n")
+					msg, err := sinatraAppClient.Set("mykey", "myvalue")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(msg).To(ContainSubstring("myvalue"))
 
-				fmt.Printf("\n*** Curling app\n")
-				msg, err = sinatraAppClient.Get("mykey")
-				Expect(msg).To(ContainSubstring("myvalue"))
-				Expect(err).NotTo(HaveOccurred())
-			})
+					fmt.Printf("\n*** Curling app\n")
+					msg, err = sinatraAppClient.Get("mykey")
+					Expect(msg).To(ContainSubstring("myvalue"))
+					Expect(err).NotTo(HaveOccurred())
+
+					fmt.Printf("\n*** Unbinding and destroying the service instance\n")
+					Expect(helpers.Resources.Cleanup(helpers.TestContext.LongTimeout())).To(BeEmpty())
+				})
+			}
 
 			It("Guarantees a TLS connection to a simple Spring app", func() {
 				if !helpers.TestConfig.EnableTlsTests {
@@ -109,13 +100,11 @@ var _ = Describe("P-MySQL Lifecycle Tests", func() {
 				os.MkdirAll(fmt.Sprintf("%s/build/libs/", springPath), 0700)
 				os.Link("/var/vcap/packages/acceptance-tests/cipher_finder/cipher_finder.jar", fmt.Sprintf("%s/build/libs/cipher_finder.jar", springPath))
 
-				// cf push cipher-finder --no-start
-				Expect(cf.Cf("push", appName, "-m", "1G", "-f", fmt.Sprintf("%s/manifest.yml", springPath), "-d", helpers.TestConfig.CFConfig.AppsDomain, "--no-start").
-					Wait(helpers.TestContext.LongTimeout())).
-					To(Exit(0))
+				app, err := workflow.PushApp(appName, workflow.AppOptions{Memory: "1G", Manifest: fmt.Sprintf("%s/manifest.yml", springPath)})
+				Expect(err).NotTo(HaveOccurred())
 
-				// create-service & bind-service & start & assertAppIsRunning
-				createBindAndStartApp(helpers.TestConfig.ServiceName, plan.Name, serviceInstanceName, appName, cipherFinderAppClient)
+				cipherFinderAppClient := helpers.NewCipherFinderClient(app.URI, helpers.TestConfig.CFConfig.SkipSSLValidation)
+				createBindAndStartApp(app, plan.Name, serviceInstanceName, cipherFinderAppClient)
 
 				fmt.Printf("\n*** GET curl to url\n")
 				cipher, err := cipherFinderAppClient.Ciphers()
@@ -123,25 +112,33 @@ var _ = Describe("P-MySQL Lifecycle Tests", func() {
 				Expect(cipher).To(Equal("AES256-SHA256"))
 			})
 
-			enableServiceAccessToOrg = func(serviceName string, org string) {
-				workflowhelpers.AsUser(helpers.TestContext.AdminUserContext(), helpers.TestContext.ShortTimeout(), func() {
-					cf.Cf("enable-service-access", serviceName, "-o", org).Wait(helpers.TestContext.ShortTimeout())
-				})
-			}
+			createBindAndStartApp = func(app workflow.App, planName string, serviceInstanceName string, appClient helpers.Pinger) {
+				instance, err := workflow.CreateInstance(service.Name, planName, serviceInstanceName)
+				Expect(err).NotTo(HaveOccurred())
+				_, err = workflow.Bind(app, instance)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(workflow.StartAndWait(app, appClient)).To(Succeed())
 
-			createBindAndStartApp = func(serviceName string, planName string, serviceInstanceName string, appName string, appClient helpers.Pinger) {
-				Expect(cf.Cf("create-service", serviceName, planName, serviceInstanceName).Wait(helpers.TestContext.LongTimeout())).To(Exit(0))
-				Expect(cf.Cf("bind-service", appName, serviceInstanceName).Wait(helpers.TestContext.LongTimeout())).To(Exit(0))
-				Expect(cf.Cf("start", appName).Wait(helpers.TestContext.LongTimeout())).To(Exit(0))
-				err := appClient.Ping()
+				api, err := workflow.API()
 				Expect(err).NotTo(HaveOccurred())
-			}
 
-			cleanupServiceInstance = func(appName string, serviceInstanceName string) {
-				cf.Cf("unbind-service", appName, serviceInstanceName).Wait(helpers.TestContext.LongTimeout())
-				Expect(cf.Cf("delete-service", "-f", serviceInstanceName).Wait(helpers.TestContext.LongTimeout())).To(Exit(0))
+				found, err := api.FindApp("", app.Name)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(found.State).To(Equal("STARTED"))
+
+				droplet, err := api.CurrentDroplet(found.GUID)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(droplet.State).To(Equal("STAGED"))
 
-				Expect(cf.Cf("delete", appName, "-f").Wait(helpers.TestContext.LongTimeout())).To(Exit(0))
+				foundInstance, err := api.FindServiceInstance("", serviceInstanceName)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(foundInstance.LastOperation.State).To(Equal("succeeded"))
+
+				bindings, err := api.ServiceCredentialBindings(foundInstance.GUID)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(bindings).To(HaveLen(1))
+				Expect(bindings[0].Type).To(Equal("app"))
+				Expect(bindings[0].Relationships.GUID("app")).To(Equal(found.GUID))
 			}
 		})
 
@@ -149,25 +146,24 @@ var _ = Describe("P-MySQL Lifecycle Tests", func() {
 			var serviceInstanceName, serviceKeyName string
 
 			BeforeEach(func() {
-				serviceInstanceName = generator.PrefixedRandomName("lifecycle", "instance")
-				serviceKeyName = generator.PrefixedRandomName("lifecycle", "key")
-			})
-
-			AfterEach(func() {
-				cleanupServiceInstanceAndKey(serviceInstanceName, serviceKeyName)
+				serviceInstanceName = helpers.RandomName("LIFECYCLE-INSTANCE")
+				serviceKeyName = helpers.RandomName("LIFECYCLE-KEY")
 			})
 
 			Context("when no arbitrary parameters are provided", func() {
 				It("successfully creates a service key", func() {
-					createServiceInstanceAndKey(helpers.TestConfig.ServiceName, plan.Name, serviceInstanceName, serviceKeyName, "")
+					err := createServiceInstanceAndKey(service.Name, plan.Name, serviceInstanceName, serviceKeyName, "")
+					Expect(err).NotTo(HaveOccurred())
+					expectServiceKey(serviceInstanceName, serviceKeyName)
 				})
 			})
 
 			Context("when valid arbitrary parameters are provided", func() {
 				It("successfully creates a service key", func() {
 					arbitraryParams := `{"read-only":true}`
-					createServiceKeyCommand := createServiceInstanceAndKey(helpers.TestConfig.ServiceName, plan.Name, serviceInstanceName, serviceKeyName, arbitraryParams)
-					Expect(createServiceKeyCommand).To(Exit(0))
+					err := createServiceInstanceAndKey(service.Name, plan.Name, serviceInstanceName, serviceKeyName, arbitraryParams)
+					Expect(err).NotTo(HaveOccurred())
+					expectServiceKey(serviceInstanceName, serviceKeyName)
 				})
 			})
 
@@ -175,33 +171,61 @@ var _ = Describe("P-MySQL Lifecycle Tests", func() {
 				Context("when the key is anything other than 'read-only'", func() {
 					It("fails to create a service key", func() {
 						arbitraryParams := `{"read_only":true}`
-						createServiceKeyCommand := createServiceInstanceAndKey(helpers.TestConfig.ServiceName, plan.Name, serviceInstanceName, serviceKeyName, arbitraryParams)
-						Expect(createServiceKeyCommand).To(Exit(1))
+						err := createServiceInstanceAndKey(service.Name, plan.Name, serviceInstanceName, serviceKeyName, arbitraryParams)
+						Expect(err).To(BeAssignableToTypeOf(&helpers.CfCommandError{}))
+						Expect(err.(*helpers.CfCommandError).ExitCode).To(Equal(1))
 					})
 				})
 
 				Context("when the value of 'read-only' is not the boolean value true", func() {
 					It("fails to create a service key", func() {
 						arbitraryParams := `{"read-only":"notboolean"}`
-						createServiceKeyCommand := createServiceInstanceAndKey(helpers.TestConfig.ServiceName, plan.Name, serviceInstanceName, serviceKeyName, arbitraryParams)
-						Expect(createServiceKeyCommand).To(Exit(1))
+						err := createServiceInstanceAndKey(service.Name, plan.Name, serviceInstanceName, serviceKeyName, arbitraryParams)
+						Expect(err).To(BeAssignableToTypeOf(&helpers.CfCommandError{}))
+						Expect(err.(*helpers.CfCommandError).ExitCode).To(Equal(1))
 					})
 				})
 			})
 
-			createServiceInstanceAndKey = func(serviceName, planName, serviceInstanceName, serviceKeyName, arbitraryParams string) *Session {
-				Expect(cf.Cf("create-service", serviceName, planName, serviceInstanceName).Wait(helpers.TestContext.LongTimeout())).To(Exit(0))
-				if arbitraryParams == "" {
-					return cf.Cf("create-service-key", serviceInstanceName, serviceKeyName).Wait(helpers.TestContext.LongTimeout())
-				} else {
-					return cf.Cf("create-service-key", serviceInstanceName, serviceKeyName, "-c", arbitraryParams).Wait(helpers.TestContext.LongTimeout())
-				}
+			createServiceInstanceAndKey = func(serviceName, planName, serviceInstanceName, serviceKeyName, arbitraryParams string) error {
+				instance, err := workflow.CreateInstance(serviceName, planName, serviceInstanceName)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = workflow.CreateKey(instance, serviceKeyName, arbitraryParams)
+				return err
 			}
 
-			cleanupServiceInstanceAndKey = func(serviceInstanceName, serviceKeyName string) {
-				Expect(cf.Cf("delete-service-key", "-f", serviceInstanceName, serviceKeyName).Wait(helpers.TestContext.LongTimeout())).To(Exit(0))
-				Expect(cf.Cf("delete-service", "-f", serviceInstanceName).Wait(helpers.TestContext.LongTimeout())).To(Exit(0))
+			expectServiceKey = func(serviceInstanceName, serviceKeyName string) {
+				api, err := workflow.API()
+				Expect(err).NotTo(HaveOccurred())
+
+				instance, err := api.FindServiceInstance("", serviceInstanceName)
+				Expect(err).NotTo(HaveOccurred())
+
+				bindings, err := api.ServiceCredentialBindings(instance.GUID)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(bindings).To(HaveLen(1))
+				Expect(bindings[0].Type).To(Equal("key"))
+				Expect(bindings[0].Name).To(Equal(serviceKeyName))
+
+				credentials, err := api.ServiceCredentialBindingCredentials(bindings[0].GUID)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(credentials).To(HaveKey("hostname"))
 			}
 		})
 	})
 })
+
+func visiblePlanNames(service string) ([]string, error) {
+	plans, err := workflow.VisiblePlans(service)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, plan := range plans {
+		names = append(names, plan.Name)
+	}
+
+	return names, nil
+}
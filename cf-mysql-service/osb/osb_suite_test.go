@@ -0,0 +1,11 @@
+package osb_test
+
+import (
+	"testing"
+
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
+)
+
+func TestService(t *testing.T) {
+	helpers.PrepareAndRunTests("OSB", t, false, helpers.RequireService, helpers.RequireBrokerCredentials)
+}
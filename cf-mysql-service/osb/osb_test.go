@@ -0,0 +1,337 @@
+package osb_test
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/nu7hatch/gouuid"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
+)
+
+// The OSB API requires names that work on a command line.
+var cliFriendlyName = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+type provisionedInstance struct {
+	id     string
+	planID string
+}
+
+type boundBinding struct {
+	instanceID string
+	id         string
+	planID     string
+}
+
+func newGUID() string {
+	id, err := uuid.NewV4()
+	Expect(err).NotTo(HaveOccurred())
+	return id.String()
+}
+
+var _ = helpers.DescribeEachService("P-MySQL Broker OSB API", func(service helpers.Service) {
+	var (
+		client         *helpers.BrokerClient
+		catalogService helpers.BrokerService
+		plan           helpers.BrokerPlan
+		orgGUID        string
+		spaceGUID      string
+		instances      []provisionedInstance
+		bindings       []boundBinding
+	)
+
+	BeforeEach(func() {
+		client = helpers.NewBrokerClient(helpers.TestConfig)
+		orgGUID = newGUID()
+		spaceGUID = newGUID()
+		instances = nil
+		bindings = nil
+
+		catalog, err := client.Catalog()
+		Expect(err).NotTo(HaveOccurred())
+
+		var found bool
+		catalogService, found = catalog.Service(service.Name)
+		Expect(found).To(BeTrue(), "The catalog does not offer %s", service.Name)
+
+		plan, found = catalogService.Plan(service.Plans[0].Name)
+		Expect(found).To(BeTrue(), "The catalog does not offer the plan %s", service.Plans[0].Name)
+	})
+
+	// the broker creates databases and users that Cloud Controller does not
+	// know of, so nothing but these specs can delete them
+	AfterEach(func() {
+		cleanupClient := helpers.NewBrokerClient(helpers.TestConfig)
+
+		for i := len(bindings) - 1; i >= 0; i-- {
+			binding := bindings[i]
+			resp, err := cleanupClient.Unbind(binding.instanceID, binding.id, catalogService.ID, binding.planID)
+			reportCleanupFailure("Unbinding "+binding.id, resp, err)
+		}
+
+		for i := len(instances) - 1; i >= 0; i-- {
+			instance := instances[i]
+			resp, err := cleanupClient.Deprovision(instance.id, catalogService.ID, instance.planID, false)
+			reportCleanupFailure("Deprovisioning "+instance.id, resp, err)
+		}
+	})
+
+	provisionRequest := func(planID string) helpers.BrokerProvisionRequest {
+		return helpers.BrokerProvisionRequest{
+			ServiceID:        catalogService.ID,
+			PlanID:           planID,
+			OrganizationGUID: orgGUID,
+			SpaceGUID:        spaceGUID,
+		}
+	}
+
+	provision := func(instanceID string, request helpers.BrokerProvisionRequest) *helpers.BrokerResponse {
+		instances = append(instances, provisionedInstance{id: instanceID, planID: request.PlanID})
+
+		resp, err := client.Provision(instanceID, request, false)
+		Expect(err).NotTo(HaveOccurred())
+		return resp
+	}
+
+	bindRequest := func(planID, appGUID string) helpers.BrokerBindRequest {
+		return helpers.BrokerBindRequest{ServiceID: catalogService.ID, PlanID: planID, AppGUID: appGUID}
+	}
+
+	bind := func(instanceID, bindingID string, request helpers.BrokerBindRequest) *helpers.BrokerResponse {
+		bindings = append(bindings, boundBinding{instanceID: instanceID, id: bindingID, planID: request.PlanID})
+
+		resp, err := client.Bind(instanceID, bindingID, request)
+		Expect(err).NotTo(HaveOccurred())
+		return resp
+	}
+
+	Describe("the catalog", func() {
+		It("is valid", func() {
+			catalog, err := client.Catalog()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(catalog.Services).NotTo(BeEmpty())
+
+			ids := map[string]bool{}
+			for _, catalogService := range catalog.Services {
+				Expect(catalogService.ID).NotTo(BeEmpty())
+				Expect(ids).NotTo(HaveKey(catalogService.ID), "IDs must be unique")
+				ids[catalogService.ID] = true
+
+				Expect(catalogService.Name).To(MatchRegexp(cliFriendlyName.String()))
+				Expect(catalogService.Description).NotTo(BeEmpty())
+				Expect(catalogService.Plans).NotTo(BeEmpty())
+
+				for _, catalogPlan := range catalogService.Plans {
+					Expect(catalogPlan.ID).NotTo(BeEmpty())
+					Expect(ids).NotTo(HaveKey(catalogPlan.ID), "IDs must be unique")
+					ids[catalogPlan.ID] = true
+
+					Expect(catalogPlan.Name).To(MatchRegexp(cliFriendlyName.String()))
+					Expect(catalogPlan.Description).NotTo(BeEmpty())
+				}
+			}
+		})
+
+		It("offers the configured plans", func() {
+			for _, configuredPlan := range service.Plans {
+				_, found := catalogService.Plan(configuredPlan.Name)
+				Expect(found).To(BeTrue(), "The catalog does not offer the plan %s", configuredPlan.Name)
+			}
+		})
+	})
+
+	Describe("the X-Broker-API-Version header", func() {
+		It("is required", func() {
+			client.APIVersion = ""
+
+			resp, err := client.Request("GET", "/v2/catalog", nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusPreconditionFailed))
+		})
+
+		It("must name version 2", func() {
+			client.APIVersion = "1.0"
+
+			resp, err := client.Request("GET", "/v2/catalog", nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusPreconditionFailed))
+		})
+	})
+
+	Describe("provisioning", func() {
+		It("creates an instance once and refuses conflicting requests", func() {
+			instanceID := newGUID()
+
+			resp := provision(instanceID, provisionRequest(plan.ID))
+			Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+
+			var provisioned helpers.BrokerProvisionResponse
+			Expect(resp.Decode(&provisioned)).To(Succeed())
+			Expect(provisioned.DashboardURL).NotTo(BeEmpty())
+
+			resp = provision(instanceID, provisionRequest(plan.ID))
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(resp.Decode(&provisioned)).To(Succeed())
+
+			conflicting := provisionRequest(plan.ID)
+			conflicting.SpaceGUID = newGUID()
+			resp = provision(instanceID, conflicting)
+			Expect(resp.StatusCode).To(Equal(http.StatusConflict))
+			Expect(resp.Body).To(MatchJSON("{}"))
+		})
+
+		It("deprovisions an instance once", func() {
+			instanceID := newGUID()
+			Expect(provision(instanceID, provisionRequest(plan.ID)).StatusCode).To(Equal(http.StatusCreated))
+
+			resp, err := client.Deprovision(instanceID, catalogService.ID, plan.ID, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(resp.Body).To(MatchJSON("{}"))
+
+			resp, err = client.Deprovision(instanceID, catalogService.ID, plan.ID, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusGone))
+		})
+
+		It("answers 410 for an instance it never provisioned", func() {
+			resp, err := client.Deprovision(newGUID(), catalogService.ID, plan.ID, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusGone))
+		})
+	})
+
+	Describe("binding", func() {
+		var instanceID string
+
+		BeforeEach(func() {
+			instanceID = newGUID()
+			Expect(provision(instanceID, provisionRequest(plan.ID)).StatusCode).To(Equal(http.StatusCreated))
+		})
+
+		It("creates a binding once and refuses conflicting requests", func() {
+			bindingID := newGUID()
+			appGUID := newGUID()
+
+			resp := bind(instanceID, bindingID, bindRequest(plan.ID, appGUID))
+			Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+
+			var bound helpers.BrokerBindResponse
+			Expect(resp.Decode(&bound)).To(Succeed())
+			for _, key := range []string{"hostname", "port", "name", "username", "password", "uri", "jdbcUrl"} {
+				Expect(bound.Credentials).To(HaveKey(key))
+			}
+
+			resp = bind(instanceID, bindingID, bindRequest(plan.ID, appGUID))
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(resp.Decode(&bound)).To(Succeed())
+			Expect(bound.Credentials).To(HaveKey("uri"))
+
+			resp = bind(instanceID, bindingID, bindRequest(plan.ID, newGUID()))
+			Expect(resp.StatusCode).To(Equal(http.StatusConflict))
+		})
+
+		It("unbinds a binding once", func() {
+			bindingID := newGUID()
+			Expect(bind(instanceID, bindingID, bindRequest(plan.ID, newGUID())).StatusCode).To(Equal(http.StatusCreated))
+
+			resp, err := client.Unbind(instanceID, bindingID, catalogService.ID, plan.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(resp.Body).To(MatchJSON("{}"))
+
+			resp, err = client.Unbind(instanceID, bindingID, catalogService.ID, plan.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusGone))
+		})
+	})
+
+	Describe("updating", func() {
+		It("answers 422 when it refuses a plan change", func() {
+			// the data written must fit under max_quota_test_mb, as in the
+			// quota suite
+			downgrades := service.Downgrades(helpers.TestConfig.MaxQuotaTestMb)
+			if len(downgrades) == 0 {
+				Skip(fmt.Sprintf("Skipping as no two plans differ in storage with the smaller at most %d MB", helpers.TestConfig.MaxQuotaTestMb))
+			}
+			small, large := downgrades[0].To, downgrades[0].From
+
+			smallPlan, found := catalogService.Plan(small.Name)
+			Expect(found).To(BeTrue())
+			largePlan, found := catalogService.Plan(large.Name)
+			Expect(found).To(BeTrue())
+
+			instanceID := newGUID()
+			Expect(provision(instanceID, provisionRequest(largePlan.ID)).StatusCode).To(Equal(http.StatusCreated))
+
+			// a broker that changes plans refuses to move an instance to a
+			// plan its data does not fit in
+			if catalogService.PlanUpdateable {
+				resp := bind(instanceID, newGUID(), bindRequest(largePlan.ID, newGUID()))
+				Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+
+				var bound helpers.BrokerBindResponse
+				Expect(resp.Decode(&bound)).To(Succeed())
+
+				fmt.Printf("\n*** Writing %dMB to the instance\n", small.MaxStorageMb+1)
+				Expect(writeData(bound.Credentials, small.MaxStorageMb+1)).To(Succeed())
+			}
+
+			resp, err := client.Update(instanceID, helpers.BrokerUpdateRequest{
+				ServiceID:      catalogService.ID,
+				PlanID:         smallPlan.ID,
+				PreviousValues: &helpers.BrokerPreviousValues{ServiceID: catalogService.ID, PlanID: largePlan.ID},
+			}, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusUnprocessableEntity))
+
+			var refused helpers.BrokerErrorResponse
+			Expect(resp.Decode(&refused)).To(Succeed())
+			Expect(refused.Description).NotTo(BeEmpty())
+		})
+	})
+})
+
+// reportCleanupFailure prints what could not be deleted, like the cleanup of
+// the resources created through Cloud Controller. Gone is as good as deleted.
+func reportCleanupFailure(step string, resp *helpers.BrokerResponse, err error) {
+	switch {
+	case err != nil:
+		fmt.Printf("Cleanup failed: %s: %s\n", step, err.Error())
+	case resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusGone:
+		fmt.Printf("Cleanup failed: %s: the broker answered %d: %s\n", step, resp.StatusCode, resp.Body)
+	}
+}
+
+// writeData fills a table in the bound database with megabytes of data and
+// refreshes its statistics, which the broker measures the storage used by.
+func writeData(credentials map[string]interface{}, megabytes int) error {
+	db, err := sql.Open("mysql", fmt.Sprintf("%v:%v@tcp(%v:%v)/%v",
+		credentials["username"],
+		credentials["password"],
+		credentials["hostname"],
+		credentials["port"],
+		credentials["name"]))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE TABLE data (id INT AUTO_INCREMENT PRIMARY KEY, value LONGBLOB)"); err != nil {
+		return err
+	}
+
+	for i := 0; i < megabytes; i++ {
+		if _, err := db.Exec("INSERT INTO data (value) VALUES (REPEAT('x', 1024 * 1024))"); err != nil {
+			return err
+		}
+	}
+
+	_, err = db.Exec("ANALYZE TABLE data")
+	return err
+}
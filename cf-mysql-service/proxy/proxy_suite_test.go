@@ -11,7 +11,7 @@ import (
 )
 
 func TestService(t *testing.T) {
-	helpers.PrepareAndRunTests("Proxy", t, false)
+	helpers.PrepareAndRunTests("Proxy", t, false, helpers.RequireProxy)
 }
 
 var _ = BeforeSuite(func() {
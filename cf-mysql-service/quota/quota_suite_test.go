@@ -1,11 +1,30 @@
 package quota_test
 
 import (
+	"fmt"
 	"testing"
 
+	. "github.com/onsi/ginkgo"
+
 	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
 )
 
 func TestService(t *testing.T) {
-	helpers.PrepareAndRunTests("Quota", t, true)
+	helpers.PrepareAndRunTests("Quota", t, true, helpers.RequireCF, helpers.RequireService)
 }
+
+// Only plans up to max_quota_test_mb are filled up. Say once which
+// services that leaves without storage quota specs.
+var _ = SynchronizedBeforeSuite(func() []byte {
+	maxStorageMb := helpers.TestConfig.MaxQuotaTestMb
+	for _, service := range helpers.TestConfig.AllServices() {
+		if len(service.PlansUpTo(maxStorageMb)) == 0 {
+			fmt.Printf("Not enforcing the storage quota of %s: no plan has a storage quota of at most %d MB (max_quota_test_mb)\n", service.Name, maxStorageMb)
+		}
+		if len(service.Upgrades(maxStorageMb)) == 0 {
+			fmt.Printf("Not migrating %s between plans: no two plans differ in storage quota with the smaller at most %d MB (max_quota_test_mb)\n", service.Name, maxStorageMb)
+		}
+	}
+
+	return nil
+}, func([]byte) {})
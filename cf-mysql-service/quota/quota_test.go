@@ -7,11 +7,9 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
-	. "github.com/onsi/gomega/gbytes"
-	. "github.com/onsi/gomega/gexec"
 
 	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
-	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers/workflow"
 	"github.com/cloudfoundry-incubator/cf-test-helpers/generator"
 )
 
@@ -21,37 +19,35 @@ const (
 	quotaEnforcerSleepTime = 20 * time.Second
 )
 
-var _ = Describe("P-MySQL Service", func() {
+var _ = helpers.DescribeEachService("P-MySQL Service", func(service helpers.Service) {
 	var sinatraPath = "../../assets/sinatra_app"
 
 	Describe("Enforcing MySQL storage and connection quota", func() {
-		var appName string
-		var serviceInstanceName string
+		var app workflow.App
+		var instance workflow.ServiceInstance
 		var plan helpers.Plan
 		var appClient helpers.SinatraAppClient
 
 		BeforeEach(func() {
-			appName = generator.PrefixedRandomName("quota", "app")
-			serviceInstanceName = generator.PrefixedRandomName("quota", "instance")
-			plan = helpers.TestConfig.Plans[0]
-			appClient = helpers.NewSinatraAppClient(helpers.TestConfig.AppURI(appName), serviceInstanceName, helpers.TestConfig.CFConfig.SkipSSLValidation)
+			// the plans filled up include private ones
+			Expect(workflow.EnableServiceAccess(service.Name, helpers.TestContext.RegularUserContext().Org)).To(Succeed())
 
-			Expect(cf.Cf("push", appName, "-m", "256M", "-p", sinatraPath, "-b", "ruby_buildpack", "--no-start").Wait(helpers.TestContext.LongTimeout())).To(Exit(0))
+			var err error
+			app, err = workflow.PushApp(helpers.RandomName("QUOTA-APP"), workflow.AppOptions{Memory: "256M", Path: sinatraPath, Buildpack: "ruby_buildpack"})
+			Expect(err).NotTo(HaveOccurred())
 		})
 
 		JustBeforeEach(func() {
-			fmt.Printf("Creating service with serviceName: %s, planName: %s, serviceInstanceName: %s\n", helpers.TestConfig.ServiceName, plan.Name, serviceInstanceName)
-			Expect(cf.Cf("create-service", helpers.TestConfig.ServiceName, plan.Name, serviceInstanceName).Wait(helpers.TestContext.LongTimeout())).To(Exit(0))
-			Expect(cf.Cf("bind-service", appName, serviceInstanceName).Wait(helpers.TestContext.LongTimeout())).To(Exit(0))
-			Expect(cf.Cf("start", appName).Wait(helpers.TestContext.LongTimeout())).To(Exit(0))
-			err := appClient.Ping()
-			Expect(err).NotTo(HaveOccurred())
-		})
+			serviceInstanceName := helpers.RandomName("QUOTA-INSTANCE")
+			appClient = helpers.NewSinatraAppClient(app.URI, serviceInstanceName, helpers.TestConfig.CFConfig.SkipSSLValidation)
 
-		AfterEach(func() {
-			Expect(cf.Cf("unbind-service", appName, serviceInstanceName).Wait(helpers.TestContext.LongTimeout())).To(Exit(0))
-			Expect(cf.Cf("delete-service", "-f", serviceInstanceName).Wait(helpers.TestContext.LongTimeout())).To(Exit(0))
-			Expect(cf.Cf("delete", appName, "-f").Wait(helpers.TestContext.LongTimeout())).To(Exit(0))
+			fmt.Printf("Creating service with serviceName: %s, planName: %s, serviceInstanceName: %s\n", service.Name, plan.Name, serviceInstanceName)
+			var err error
+			instance, err = workflow.CreateInstance(service.Name, plan.Name, serviceInstanceName)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = workflow.Bind(app, instance)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(workflow.StartAndWait(app, appClient)).To(Succeed())
 		})
 
 		ExceedLimit := func(maxStorageMb int) {
@@ -78,57 +74,67 @@ var _ = Describe("P-MySQL Service", func() {
 			appClient.WriteBulkData(strconv.Itoa(1))
 		}
 
-		// We only need to validate the storage quota enforcer operates as expected over the first plan.
-		// Especially important given the plan can be of any size, and we don't want to fill up large databases.
-		It("enforces the storage quota for the plan", func() {
-			firstValue := generator.PrefixedRandomName("", "")[:20]
-			secondValue := generator.PrefixedRandomName("", "")[:20]
+		// Plans larger than max_quota_test_mb are not filled up, as the plans
+		// can be of any size.
+		for _, quotaPlan := range service.PlansUpTo(helpers.TestConfig.MaxQuotaTestMb) {
+			quotaPlan := quotaPlan
 
-			fmt.Println("\n*** Proving we can write")
-			msg, err := appClient.Set("mykey", firstValue)
-			Expect(err).NotTo(HaveOccurred())
-			Expect(msg).To(ContainSubstring(firstValue))
+			Context(fmt.Sprintf("with the %s plan", quotaPlan.Name), func() {
+				BeforeEach(func() {
+					plan = quotaPlan
+				})
 
-			fmt.Println("\n*** Proving we can read")
-			msg, err = appClient.Get("mykey")
-			Expect(err).NotTo(HaveOccurred())
-			Expect(msg).To(ContainSubstring(firstValue))
+				It("enforces the storage quota for the plan", func() {
+					firstValue := generator.PrefixedRandomName("", "")[:20]
+					secondValue := generator.PrefixedRandomName("", "")[:20]
 
-			ExceedLimit(plan.MaxStorageMb)
+					fmt.Println("\n*** Proving we can write")
+					msg, err := appClient.Set("mykey", firstValue)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(msg).To(ContainSubstring(firstValue))
 
-			fmt.Println("\n*** Sleeping to let quota enforcer run")
-			time.Sleep(quotaEnforcerSleepTime)
+					fmt.Println("\n*** Proving we can read")
+					msg, err = appClient.Get("mykey")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(msg).To(ContainSubstring(firstValue))
 
-			fmt.Println("\n*** Proving we cannot write (expect app to fail)")
-			value := generator.PrefixedRandomName("", "")[:20]
-			_, err = appClient.Set("mykey", value)
-			Expect(err).To(MatchError(MatchRegexp("Error: (INSERT|UPDATE) command denied .* for table 'data_values'")))
+					ExceedLimit(plan.MaxStorageMb)
 
-			fmt.Println("Expected failure occured")
+					fmt.Println("\n*** Sleeping to let quota enforcer run")
+					time.Sleep(quotaEnforcerSleepTime)
 
-			fmt.Println("\n*** Proving we can read")
-			msg, err = appClient.Get("mykey")
-			Expect(err).NotTo(HaveOccurred())
-			Expect(msg).To(ContainSubstring(firstValue))
+					fmt.Println("\n*** Proving we cannot write (expect app to fail)")
+					value := generator.PrefixedRandomName("", "")[:20]
+					_, err = appClient.Set("mykey", value)
+					Expect(err).To(MatchError(MatchRegexp("Error: (INSERT|UPDATE) command denied .* for table 'data_values'")))
 
-			fmt.Println("\n*** Deleting below quota")
-			msg, err = appClient.DeleteBulkData("20")
-			Expect(err).NotTo(HaveOccurred())
-			Expect(msg).To(ContainSubstring("Database now contains"))
+					fmt.Println("Expected failure occured")
 
-			fmt.Println("\n*** Sleeping to let quota enforcer run")
-			time.Sleep(quotaEnforcerSleepTime)
+					fmt.Println("\n*** Proving we can read")
+					msg, err = appClient.Get("mykey")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(msg).To(ContainSubstring(firstValue))
 
-			fmt.Println("\n*** Proving we can write")
-			msg, err = appClient.Set("mykey", secondValue)
-			Expect(err).NotTo(HaveOccurred())
-			Expect(msg).To(ContainSubstring(secondValue))
+					fmt.Println("\n*** Deleting below quota")
+					msg, err = appClient.DeleteBulkData("20")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(msg).To(ContainSubstring("Database now contains"))
 
-			fmt.Println("\n*** Proving we can read")
-			msg, err = appClient.Get("mykey")
-			Expect(err).NotTo(HaveOccurred())
-			Expect(msg).To(ContainSubstring(secondValue))
-		})
+					fmt.Println("\n*** Sleeping to let quota enforcer run")
+					time.Sleep(quotaEnforcerSleepTime)
+
+					fmt.Println("\n*** Proving we can write")
+					msg, err = appClient.Set("mykey", secondValue)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(msg).To(ContainSubstring(secondValue))
+
+					fmt.Println("\n*** Proving we can read")
+					msg, err = appClient.Get("mykey")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(msg).To(ContainSubstring(secondValue))
+				})
+			})
+		}
 
 		// TODO: Enable this test once we complete the proxy sync epic.
 		// Dijon sends these connections through a load balancer, routing connections to both proxies that sometimes
@@ -148,98 +154,112 @@ var _ = Describe("P-MySQL Service", func() {
 		// })
 
 		Describe("Migrating a service instance between plans of different storage quota", func() {
-			Context("when upgrading to a larger storage quota", func() {
-				var newPlan helpers.Plan
-
-				BeforeEach(func() {
-					newPlan = helpers.TestConfig.Plans[1]
-				})
-
-				It("enforces the new quota", func() {
-					ExceedLimit(plan.MaxStorageMb)
-
-					fmt.Println("\n*** Sleeping to let quota enforcer run")
-					time.Sleep(quotaEnforcerSleepTime)
-
-					fmt.Println("\n*** Proving we cannot write (expect app to fail)")
-					value := generator.PrefixedRandomName("", "")[:20]
-					_, err := appClient.Set("mykey", value)
-					Expect(err).To(MatchError(MatchRegexp("Error: (INSERT|UPDATE) command denied .* for table 'data_values'")))
-					fmt.Println("Expected failure occured")
+			// Plans are paired by storage quota, with the smaller plan at most
+			// max_quota_test_mb as it is filled up.
+			for _, upgrade := range service.Upgrades(helpers.TestConfig.MaxQuotaTestMb) {
+				upgrade := upgrade
 
-					fmt.Println("\n*** Upgrading service instance")
-					Expect(cf.Cf("update-service", serviceInstanceName, "-p", newPlan.Name).Wait(helpers.TestContext.LongTimeout())).To(Exit(0))
+				Context(fmt.Sprintf("when upgrading from the %s plan to the larger %s plan", upgrade.From.Name, upgrade.To.Name), func() {
+					var newPlan helpers.Plan
 
-					fmt.Println("\n*** Sleeping to let quota enforcer run")
-					time.Sleep(quotaEnforcerSleepTime)
+					BeforeEach(func() {
+						plan = upgrade.From
+						newPlan = upgrade.To
+					})
 
-					fmt.Println("\n*** Proving we can write")
-					value = generator.PrefixedRandomName("", "")[:20]
-					//curlCmd = runner.NewCmdRunner(runner.Curl("-k", "-d", value, uri), helpers.TestContext.ShortTimeout()).Run()
-					msg, err := appClient.Set("mykey", value)
-					Expect(err).NotTo(HaveOccurred())
-					Expect(msg).To(ContainSubstring(value))
-				})
-			})
+					It("enforces the new quota", func() {
+						ExceedLimit(plan.MaxStorageMb)
 
-			Context("when attempting to downgrade to a smaller storage quota", func() {
-				var smallPlan helpers.Plan
+						fmt.Println("\n*** Sleeping to let quota enforcer run")
+						time.Sleep(quotaEnforcerSleepTime)
 
-				BeforeEach(func() {
-					plan = helpers.TestConfig.Plans[1]
-					smallPlan = helpers.TestConfig.Plans[0]
-				})
+						fmt.Println("\n*** Proving we cannot write (expect app to fail)")
+						value := generator.PrefixedRandomName("", "")[:20]
+						_, err := appClient.Set("mykey", value)
+						Expect(err).To(MatchError(MatchRegexp("Error: (INSERT|UPDATE) command denied .* for table 'data_values'")))
+						fmt.Println("Expected failure occured")
 
-				Context("when storage usage is over smaller quota", func() {
-					It("disallows downgrade", func() {
-						ExceedLimit(smallPlan.MaxStorageMb)
+						fmt.Println("\n*** Upgrading service instance")
+						_, err = workflow.UpdateInstance(instance, newPlan.Name)
+						Expect(err).NotTo(HaveOccurred())
 
 						fmt.Println("\n*** Sleeping to let quota enforcer run")
 						time.Sleep(quotaEnforcerSleepTime)
 
 						fmt.Println("\n*** Proving we can write")
-						value := generator.PrefixedRandomName("", "")[:20]
-
+						value = generator.PrefixedRandomName("", "")[:20]
+						//curlCmd = runner.NewCmdRunner(runner.Curl("-k", "-d", value, uri), helpers.TestContext.ShortTimeout()).Run()
 						msg, err := appClient.Set("mykey", value)
 						Expect(err).NotTo(HaveOccurred())
 						Expect(msg).To(ContainSubstring(value))
+					})
+				})
+			}
 
-						fmt.Println("\n*** Downgrading service instance (Expect failure)")
-						cfCmd := cf.Cf("update-service", serviceInstanceName, "-p", smallPlan.Name).Wait(helpers.TestContext.LongTimeout())
-						Expect(cfCmd).To(Exit(1))
-						Expect(cfCmd.Out).To(Say("Service broker error"))
+			for _, downgrade := range service.Downgrades(helpers.TestConfig.MaxQuotaTestMb) {
+				downgrade := downgrade
 
-						fmt.Println("Expected failure occured")
+				Context(fmt.Sprintf("when attempting to downgrade from the %s plan to the smaller %s plan", downgrade.From.Name, downgrade.To.Name), func() {
+					var smallPlan helpers.Plan
+
+					BeforeEach(func() {
+						plan = downgrade.From
+						smallPlan = downgrade.To
 					})
-				})
 
-				Context("when storage usage is under smaller quota", func() {
-					It("allows downgrade", func() {
-						ExceedLimit(0)
+					Context("when storage usage is over smaller quota", func() {
+						It("disallows downgrade", func() {
+							ExceedLimit(smallPlan.MaxStorageMb)
 
-						fmt.Println("\n*** Sleeping to let quota enforcer run")
-						time.Sleep(quotaEnforcerSleepTime)
+							fmt.Println("\n*** Sleeping to let quota enforcer run")
+							time.Sleep(quotaEnforcerSleepTime)
 
-						fmt.Println("\n*** Proving we can write")
-						value := generator.PrefixedRandomName("", "")[:20]
-						msg, err := appClient.Set("mykey", value)
-						Expect(err).NotTo(HaveOccurred())
-						Expect(msg).To(ContainSubstring(value))
+							fmt.Println("\n*** Proving we can write")
+							value := generator.PrefixedRandomName("", "")[:20]
 
-						fmt.Println("\n*** Downgrading service instance")
-						Expect(cf.Cf("update-service", serviceInstanceName, "-p", smallPlan.Name).Wait(helpers.TestContext.LongTimeout())).To(Exit(0))
+							msg, err := appClient.Set("mykey", value)
+							Expect(err).NotTo(HaveOccurred())
+							Expect(msg).To(ContainSubstring(value))
 
-						fmt.Println("\n*** Sleeping to let quota enforcer run")
-						time.Sleep(quotaEnforcerSleepTime)
+							fmt.Println("\n*** Downgrading service instance (Expect failure)")
+							_, err = workflow.UpdateInstance(instance, smallPlan.Name)
+							Expect(err).To(BeAssignableToTypeOf(&helpers.CfCommandError{}))
+							Expect(err.(*helpers.CfCommandError).ExitCode).To(Equal(1))
+							Expect(err.(*helpers.CfCommandError).Output).To(ContainSubstring("Service broker error"))
 
-						fmt.Println("\n*** Proving we can write")
-						value = generator.PrefixedRandomName("", "")[:20]
-						msg, err = appClient.Set("mykey", value)
-						Expect(err).NotTo(HaveOccurred())
-						Expect(msg).To(ContainSubstring(value))
+							fmt.Println("Expected failure occured")
+						})
+					})
+
+					Context("when storage usage is under smaller quota", func() {
+						It("allows downgrade", func() {
+							ExceedLimit(0)
+
+							fmt.Println("\n*** Sleeping to let quota enforcer run")
+							time.Sleep(quotaEnforcerSleepTime)
+
+							fmt.Println("\n*** Proving we can write")
+							value := generator.PrefixedRandomName("", "")[:20]
+							msg, err := appClient.Set("mykey", value)
+							Expect(err).NotTo(HaveOccurred())
+							Expect(msg).To(ContainSubstring(value))
+
+							fmt.Println("\n*** Downgrading service instance")
+							_, err = workflow.UpdateInstance(instance, smallPlan.Name)
+							Expect(err).NotTo(HaveOccurred())
+
+							fmt.Println("\n*** Sleeping to let quota enforcer run")
+							time.Sleep(quotaEnforcerSleepTime)
+
+							fmt.Println("\n*** Proving we can write")
+							value = generator.PrefixedRandomName("", "")[:20]
+							msg, err = appClient.Set("mykey", value)
+							Expect(err).NotTo(HaveOccurred())
+							Expect(msg).To(ContainSubstring(value))
+						})
 					})
 				})
-			})
+			}
 		})
 	})
 })
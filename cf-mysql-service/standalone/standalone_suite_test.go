@@ -10,7 +10,7 @@ import (
 )
 
 func TestService(t *testing.T) {
-	helpers.PrepareAndRunTests("Standalone", t, false)
+	helpers.PrepareAndRunTests("Standalone", t, false, helpers.RequireStandalone)
 }
 
 func uuidWithUnderscores(prefix string) string {
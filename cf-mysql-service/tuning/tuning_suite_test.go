@@ -7,5 +7,5 @@ import (
 )
 
 func TestService(t *testing.T) {
-	helpers.PrepareAndRunTests("Tuning", t, false)
+	helpers.PrepareAndRunTests("Tuning", t, false, helpers.RequireStandalone, helpers.RequireTuning)
 }
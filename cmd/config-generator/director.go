@@ -0,0 +1,45 @@
+package main
+
+import (
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
+	boshdir "github.com/cloudfoundry/bosh-cli/director"
+)
+
+func fetchDeployment(boshConfig helpers.BOSH, deploymentName string) ([]byte, []boshdir.Instance, error) {
+	director, err := helpers.NewBOSHDirector(boshConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	deployment, err := director.FindDeployment(deploymentName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	manifest, err := deployment.Manifest()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	instances, err := deployment.Instances()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return []byte(manifest), instances, nil
+}
+
+// instanceIPs returns the first IP of every instance belonging to one of the
+// given instance groups, in the order the groups appear in the manifest.
+func instanceIPs(instances []boshdir.Instance, groups []helpers.InstanceGroup) []string {
+	var ips []string
+	for _, group := range groups {
+		for _, instance := range instances {
+			if instance.Group == group.Name && len(instance.IPs) > 0 {
+				ips = append(ips, instance.IPs[0])
+			}
+		}
+	}
+
+	return ips
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	placeholderRegex         = regexp.MustCompile(`\(\((!?[-/\.\w\pL]+)\)\)`)
+	anchoredPlaceholderRegex = regexp.MustCompile(`\A\(\((!?[-/\.\w\pL]+)\)\)\z`)
+)
+
+// variables holds the values used to resolve ((placeholders)), keyed by
+// variable name.
+type variables map[string]interface{}
+
+type varsFiles []string
+
+func (v *varsFiles) String() string {
+	return strings.Join(*v, ",")
+}
+
+func (v *varsFiles) Set(value string) error {
+	*v = append(*v, value)
+	return nil
+}
+
+// loadVarsFile reads a vars-store or vars-file, which is a YAML map of
+// variable names to values.
+func (v variables) loadVarsFile(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var vars map[string]interface{}
+	if err := yaml.Unmarshal(b, &vars); err != nil {
+		return fmt.Errorf("Parsing vars file '%s': %s", path, err.Error())
+	}
+
+	for name, value := range vars {
+		v[name] = value
+	}
+
+	return nil
+}
+
+// loadCredHubExport reads the output of `credhub export` (JSON or YAML).
+// Credentials are stored under their full CredHub path as well as their
+// basename, since manifests usually refer to them relative to the deployment.
+func (v variables) loadCredHubExport(exportPath string) error {
+	b, err := ioutil.ReadFile(exportPath)
+	if err != nil {
+		return err
+	}
+
+	var export struct {
+		Credentials []struct {
+			Name  string      `yaml:"name"`
+			Type  string      `yaml:"type"`
+			Value interface{} `yaml:"value"`
+		} `yaml:"credentials"`
+	}
+
+	if err := yaml.Unmarshal(b, &export); err != nil {
+		return fmt.Errorf("Parsing CredHub export '%s': %s", exportPath, err.Error())
+	}
+
+	for _, credential := range export.Credentials {
+		v[credential.Name] = credential.Value
+		v[path.Base(credential.Name)] = credential.Value
+	}
+
+	return nil
+}
+
+func (v variables) lookup(name string) (interface{}, bool) {
+	name = strings.TrimPrefix(name, "!")
+
+	if value, found := v[name]; found {
+		return value, true
+	}
+
+	// ((certificate.ca)) refers to the 'ca' key of the 'certificate' variable
+	pieces := strings.Split(name, ".")
+	value, found := v[pieces[0]]
+	if !found {
+		return nil, false
+	}
+
+	for _, key := range pieces[1:] {
+		switch typed := value.(type) {
+		case map[interface{}]interface{}:
+			value, found = typed[key]
+		case map[string]interface{}:
+			value, found = typed[key]
+		default:
+			found = false
+		}
+
+		if !found {
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+// interpolate replaces ((placeholders)) in the manifest the same way
+// `bosh interpolate` does. Placeholders without a value are left untouched.
+func (v variables) interpolate(manifest []byte) ([]byte, error) {
+	var obj interface{}
+	if err := yaml.Unmarshal(manifest, &obj); err != nil {
+		return nil, err
+	}
+
+	obj, err := v.interpolateNode(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	return yaml.Marshal(obj)
+}
+
+func (v variables) interpolateNode(node interface{}) (interface{}, error) {
+	switch typed := node.(type) {
+	case map[interface{}]interface{}:
+		for key, value := range typed {
+			interpolated, err := v.interpolateNode(value)
+			if err != nil {
+				return nil, err
+			}
+
+			typed[key] = interpolated
+		}
+
+	case []interface{}:
+		for i, value := range typed {
+			interpolated, err := v.interpolateNode(value)
+			if err != nil {
+				return nil, err
+			}
+
+			typed[i] = interpolated
+		}
+
+	case string:
+		// preserve the type of the value when it replaces the whole field
+		if match := anchoredPlaceholderRegex.FindStringSubmatch(typed); match != nil {
+			if value, found := v.lookup(match[1]); found {
+				return value, nil
+			}
+
+			return typed, nil
+		}
+
+		var err error
+		interpolated := placeholderRegex.ReplaceAllStringFunc(typed, func(placeholder string) string {
+			name := placeholderRegex.FindStringSubmatch(placeholder)[1]
+
+			value, found := v.lookup(name)
+			if !found {
+				return placeholder
+			}
+
+			switch value.(type) {
+			case string, int, int64, uint64, float64:
+				return fmt.Sprintf("%v", value)
+			default:
+				err = fmt.Errorf("Invalid type '%T' for variable '%s'. Supported types for interpolation within a string are integers and strings.", value, name)
+				return placeholder
+			}
+		})
+
+		return interpolated, err
+	}
+
+	return node, nil
+}
+
+// unresolvedVariables returns the sorted, unique names of all placeholders
+// remaining in the given document.
+func unresolvedVariables(document []byte) []string {
+	seen := map[string]bool{}
+	var names []string
+
+	for _, match := range placeholderRegex.FindAllSubmatch(document, -1) {
+		name := strings.TrimPrefix(string(match[1]), "!")
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
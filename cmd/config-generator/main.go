@@ -4,45 +4,121 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
-	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
-	"gopkg.in/yaml.v2"
 	"io/ioutil"
 	"os"
 	"strings"
+
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
+	boshdir "github.com/cloudfoundry/bosh-cli/director"
+	"gopkg.in/yaml.v2"
 )
 
+const defaultProxyAPIPort = 8080
+
 func main() {
 	var (
-		manifestPath string
-		manifest     helpers.MysqlManifest
+		manifestPath   string
+		boshCACertPath string
+		varsStorePath  string
+		varsFilePaths  varsFiles
+		credHubExport  string
+		deploymentName string
+		manifest       helpers.MysqlManifest
+		instances      []boshdir.Instance
+		bosh           helpers.BOSH
+		tuning         helpers.Tuning
 	)
 
 	flag.StringVar(&manifestPath, "manifestPath", "", "Path to the manifest yml to parse")
+	flag.StringVar(&bosh.URL, "boshURL", os.Getenv("BOSH_ENVIRONMENT"), "Address of the BOSH director (defaults to $BOSH_ENVIRONMENT)")
+	flag.StringVar(&bosh.Client, "boshClient", os.Getenv("BOSH_CLIENT"), "BOSH UAA client (defaults to $BOSH_CLIENT)")
+	flag.StringVar(&bosh.ClientSecret, "boshClientSecret", os.Getenv("BOSH_CLIENT_SECRET"), "BOSH UAA client secret (defaults to $BOSH_CLIENT_SECRET)")
+	flag.StringVar(&bosh.UAAURL, "boshUAAURL", "", "URL of the BOSH director's UAA (defaults to https://<boshURL>:8443)")
+	flag.StringVar(&boshCACertPath, "boshCACertPath", "", "Path to the CA certificate of the BOSH director")
+	flag.StringVar(&deploymentName, "deployment", "", "Name of a deployment to fetch from the BOSH director instead of reading -manifestPath")
+	flag.StringVar(&tuning.ExpectationFilePath, "tuningExpectationFilePath", "", "Path to the expected MySQL variables for the tuning suite")
+	flag.StringVar(&varsStorePath, "vars-store", "", "Path to the vars-store used to deploy the manifest")
+	flag.Var(&varsFilePaths, "vars-file", "Path to a YAML file of variables (can be repeated)")
+	flag.StringVar(&credHubExport, "credhub-export", "", "Path to the output of `credhub export` for the deployment")
 	flag.Parse()
 
-	f, err := os.Open(manifestPath)
+	vars := variables{}
+
+	if credHubExport != "" {
+		if err := vars.loadCredHubExport(credHubExport); err != nil {
+			panic(err)
+		}
+	}
+
+	if varsStorePath != "" {
+		if err := vars.loadVarsFile(varsStorePath); err != nil {
+			panic(err)
+		}
+	}
+
+	for _, path := range varsFilePaths {
+		if err := vars.loadVarsFile(path); err != nil {
+			panic(err)
+		}
+	}
+
+	if boshCACertPath != "" {
+		caCert, err := ioutil.ReadFile(boshCACertPath)
+		if err != nil {
+			panic(err)
+		}
+
+		bosh.CACert = string(caCert)
+	}
+
+	var m []byte
+	if deploymentName != "" {
+		var err error
+		m, instances, err = fetchDeployment(bosh, deploymentName)
+		if err != nil {
+			panic(err)
+		}
+	} else {
+		f, err := os.Open(manifestPath)
+		if err != nil {
+			panic(err)
+		}
+
+		m, err = ioutil.ReadAll(f)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	m, err := vars.interpolate(m)
 	if err != nil {
 		panic(err)
 	}
 
-	m, err := ioutil.ReadAll(f)
+	err = yaml.Unmarshal(m, &manifest)
 	if err != nil {
 		panic(err)
 	}
 
-	err = yaml.Unmarshal(m, &manifest)
+	cfg, err := makeIntegrationConfig(manifest, instances)
 	if err != nil {
 		panic(err)
 	}
 
-	cfg := makeIntegrationConfig(manifest)
+	cfg.BOSH = bosh
+	cfg.Tuning = tuning
 
-	err = helpers.ValidateConfig(cfg)
+	b, err := json.Marshal(cfg)
 	if err != nil {
 		panic(err)
 	}
 
-	b, err := json.Marshal(cfg)
+	if names := unresolvedVariables(b); len(names) > 0 {
+		fmt.Fprintf(os.Stderr, "Expected to find variables:\n  - %s\n", strings.Join(names, "\n  - "))
+		os.Exit(1)
+	}
+
+	err = helpers.ValidateConfig(cfg)
 	if err != nil {
 		panic(err)
 	}
@@ -52,51 +128,119 @@ func main() {
 	os.Exit(0)
 }
 
-func makeIntegrationConfig(manifest helpers.MysqlManifest) *helpers.MysqlIntegrationConfig {
-	cfg := &helpers.MysqlIntegrationConfig{}
+// makeIntegrationConfig builds the config from the manifest. When instances
+// reported by the director are given, their IPs are used in place of the
+// static IPs and instance counts declared in the manifest.
+func makeIntegrationConfig(manifest helpers.MysqlManifest, instances []boshdir.Instance) (*helpers.MysqlIntegrationConfig, error) {
+	cfg := &helpers.MysqlIntegrationConfig{
+		CFConfig:       helpers.NewCFConfig(),
+		BrokerProtocol: "https",
+		MaxQuotaTestMb: helpers.DefaultMaxQuotaTestMb,
+	}
 
-	p := manifest.Properties
+	p, err := mergedProperties(manifest)
+	if err != nil {
+		return nil, err
+	}
 
-	cfg.ApiEndpoint = p.CF.APIURL
-	cfg.AppsDomain = p.CF.AppDomains[0]
-	cfg.AdminUser = p.CF.AdminUsername
-	cfg.AdminPassword = p.CF.AdminPassword
-	cfg.ConfigurableTestPassword = p.CFMySQL.SmokeTests.Password
+	cfg.CFConfig.ApiEndpoint = p.CF.APIURL
+	cfg.CFConfig.AppsDomain = p.CF.AppsDomain
+	if cfg.CFConfig.AppsDomain == "" && len(p.CF.AppDomains) > 0 {
+		cfg.CFConfig.AppsDomain = p.CF.AppDomains[0]
+	}
+	cfg.CFConfig.AdminUser = p.CF.AdminUsername
+	cfg.CFConfig.AdminPassword = p.CF.AdminPassword
+	cfg.CFConfig.ConfigurableTestPassword = p.CFMySQL.SmokeTests.Password
 	cfg.BrokerHost = p.CFMySQL.ExternalHost
+	cfg.BrokerUsername = p.CFMySQL.Broker.AuthUsername
+	cfg.BrokerPassword = p.CFMySQL.Broker.AuthPassword
 
-	mysqlService := p.CFMySQL.Broker.Services[0]
-	cfg.ServiceName = mysqlService.Name
+	if len(p.CFMySQL.Broker.Services) == 0 {
+		return nil, fmt.Errorf("Manifest does not define any 'cf_mysql.broker.services'")
+	}
 
 	if p.CF.SmokeTests.UseExistingOrg {
-		cfg.OrgName = p.CF.SmokeTests.Org
+		cfg.CFConfig.UseExistingOrganization = true
+		cfg.CFConfig.ExistingOrganization = p.CF.SmokeTests.Org
 	}
 
-	for _, plan := range mysqlService.Plans {
-		var c int
-		if plan.MaxUserConnections == 0 {
-			c = mysqlService.MaxUserConnectionsDefault
-		} else {
-			c = plan.MaxUserConnections
+	for _, mysqlService := range p.CFMySQL.Broker.Services {
+		service := helpers.Service{Name: mysqlService.Name}
+
+		for _, plan := range mysqlService.Plans {
+			var c int
+			if plan.MaxUserConnections == 0 {
+				c = mysqlService.MaxUserConnectionsDefault
+			} else {
+				c = plan.MaxUserConnections
+			}
+
+			service.Plans = append(service.Plans, helpers.Plan{
+				Name:               plan.Name,
+				Description:        plan.Description,
+				Bullets:            plan.Bullets,
+				Private:            plan.Private,
+				MaxStorageMb:       plan.MaxStorageMB,
+				MaxUserConnections: c,
+			})
 		}
 
-		cfg.Plans = append(cfg.Plans, helpers.Plan{
-			Name:               plan.Name,
-			Private:            plan.Private,
-			MaxStorageMb:       plan.MaxStorageMB,
-			MaxUserConnections: c,
-		})
+		cfg.Services = append(cfg.Services, service)
 	}
 
-	cfg.SkipSSLValidation = p.CF.SkipSSLValidation
+	cfg.CFConfig.SkipSSLValidation = p.CF.SkipSSLValidation
+
+	proxyGroups := groupsRunning(manifest, proxyJobName)
+	mysqlGroups := groupsRunning(manifest, mysqlJobName)
+	brokerGroups := groupsRunning(manifest, brokerJobName)
+	arbitratorGroups := groupsRunning(manifest, arbitratorJobName)
+
+	if instances != nil {
+		apiPort := p.CFMySQL.Proxy.APIPort
+		if apiPort == 0 {
+			apiPort = defaultProxyAPIPort
+		}
+
+		for i, ip := range instanceIPs(instances, proxyGroups) {
+			cfg.Proxy.DashboardUrls = append(cfg.Proxy.DashboardUrls, proxyDashboardURL(i, p.CFMySQL.ExternalHost, ip, apiPort))
+		}
+
+		for _, ip := range instanceIPs(instances, mysqlGroups) {
+			cfg.MysqlNodes = append(cfg.MysqlNodes, helpers.Component{Ip: ip})
+		}
+
+		for _, ip := range instanceIPs(instances, brokerGroups) {
+			cfg.Brokers = append(cfg.Brokers, helpers.Component{Ip: ip})
+		}
+
+		for _, ip := range instanceIPs(instances, arbitratorGroups) {
+			cfg.Arbitrators = append(cfg.Arbitrators, helpers.Component{Ip: ip})
+		}
+	} else {
+		var counter int
+		for _, group := range proxyGroups {
+			for i := 0; i < group.Instances; i++ {
+				cfg.Proxy.DashboardUrls = append(cfg.Proxy.DashboardUrls, proxyDashboardURL(counter, p.CFMySQL.ExternalHost, "", 0))
+				counter++
+			}
+		}
 
-	externalHost := p.CFMySQL.ExternalHost
-	var counter int
-	for _, job := range manifest.Jobs {
-		if strings.HasPrefix(job.Name, "proxy") {
-			s := fmt.Sprintf("https://proxy-%d-%s", counter, externalHost)
-			counter++
+		for _, group := range mysqlGroups {
+			for _, ip := range group.StaticIPs() {
+				cfg.MysqlNodes = append(cfg.MysqlNodes, helpers.Component{Ip: ip})
+			}
+		}
 
-			cfg.Proxy.DashboardUrls = append(cfg.Proxy.DashboardUrls, s)
+		for _, group := range brokerGroups {
+			for _, ip := range group.StaticIPs() {
+				cfg.Brokers = append(cfg.Brokers, helpers.Component{Ip: ip})
+			}
+		}
+
+		for _, group := range arbitratorGroups {
+			for _, ip := range group.StaticIPs() {
+				cfg.Arbitrators = append(cfg.Arbitrators, helpers.Component{Ip: ip})
+			}
 		}
 	}
 
@@ -105,12 +249,25 @@ func makeIntegrationConfig(manifest helpers.MysqlManifest) *helpers.MysqlIntegra
 	cfg.Proxy.APIPassword = p.CFMySQL.Proxy.APIPassword
 	cfg.Proxy.APIForceHTTPS = p.CFMySQL.Proxy.APIForceHTTPS
 
-	cfg.TimeoutScale = p.CFMySQL.SmokeTests.TimeoutScale
+	cfg.CFConfig.TimeoutScale = p.CFMySQL.SmokeTests.TimeoutScale
+	if cfg.CFConfig.TimeoutScale <= 0 {
+		cfg.CFConfig.TimeoutScale = 1.0
+	}
 
 	cfg.Standalone.Host = p.CFMySQL.Host
 	cfg.Standalone.Port = p.CFMySQL.MySQL.Port
 	cfg.Standalone.MySQLUsername = p.CFMySQL.MySQL.AdminUsername
 	cfg.Standalone.MySQLPassword = p.CFMySQL.MySQL.AdminPassword
 
-	return cfg
+	return cfg, nil
+}
+
+// proxyDashboardURL returns the route registered for the proxy with the given
+// index. Without an external host the proxy API is addressed by IP instead.
+func proxyDashboardURL(index int, externalHost, ip string, apiPort int) string {
+	if externalHost == "" && ip != "" {
+		return fmt.Sprintf("http://%s:%d", ip, apiPort)
+	}
+
+	return fmt.Sprintf("https://proxy-%d-%s", index, externalHost)
 }
@@ -1,16 +1,24 @@
 package main_test
 
 import (
+	"encoding/json"
+	"encoding/pem"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os/exec"
 	"path/filepath"
+	"sync"
 
 	"bytes"
 
 	"os"
 
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
 	"github.com/onsi/gomega/gexec"
 )
 
@@ -32,96 +40,248 @@ var _ = Describe("Configuration Generator Command", func() {
 		os.RemoveAll(tmpDir)
 	})
 
-	It("turns a manifest into the correct integration configuration", func() {
-		config := `{
-				"api": "https://api.bosh-lite.com",
-				"apps_domain": "example.com",
-				"admin_user": "admin",
-				"admin_password": "foobar",
-				"broker_host": "p-mysql.bosh-lite.com",
-				"service_name": "p-mysql",
-				"create_permissive_security_group": false,
-				"org_name": "system",
-				"plans" : [
-					{
-						"plan_name": "10mb",
-						"max_user_connections": 20,
-						"max_storage_mb": 10,
-						"private": true
-					},
-					{
-						"plan_name": "100mb",
-						"max_user_connections": 40,
-						"max_storage_mb": 100
-					}
-				],
-				"skip_ssl_validation": true,
-				"proxy": {
-					"dashboard_urls": [
-						"https://proxy-0-p-mysql.bosh-lite.com",
-						"https://proxy-1-p-mysql.bosh-lite.com",
-						"https://proxy-2-p-mysql.bosh-lite.com"
-					],
-					"api_username":"admin",
-					"api_password":"barfoo",
-					"api_force_https": true,
-					"skip_ssl_validation": true
-				},
-				"standalone": {
-					"host": "bosh-lite.com",
-					"port": 5432,
-					"username": "admin",
-					"password": "password"
-				},
-				"test_password": "meowth",
-				"timeout_scale": 2.0
-			}`
+	runGenerator := func(path string, extraArgs ...string) (*gexec.Session, *bytes.Buffer) {
+		args := []string{
+			"-manifestPath", path,
+			"-boshURL", "192.168.50.6",
+			"-boshClient", "admin",
+			"-boshClientSecret", "bosh-secret",
+			"-boshCACertPath", filepath.Join("fixtures", "bosh-ca.pem"),
+			"-tuningExpectationFilePath", "/var/vcap/packages/acceptance-tests/tuning.json",
+		}
 
+		configureCmd := exec.Command(binPath, append(args, extraArgs...)...)
+
+		var (
+			stdOut bytes.Buffer
+		)
+
+		sess, err := gexec.Start(configureCmd, &stdOut, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+
+		<-sess.Exited
+
+		return sess, &stdOut
+	}
+
+	generateConfig := func(path string, extraArgs ...string) []byte {
+		sess, stdOut := runGenerator(path, extraArgs...)
+		Expect(sess.ExitCode()).To(Equal(0))
+
+		return stdOut.Bytes()
+	}
+
+	DescribeTable("turns a manifest into the correct integration configuration",
+		func(fixture string) {
+			expectedConfig, err := ioutil.ReadFile(filepath.Join("fixtures", fixture+".json"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(generateConfig(filepath.Join("fixtures", fixture+".yml"))).To(MatchJSON(expectedConfig))
+		},
+		Entry("a v1 manifest with jobs spread across availability zones", "v1-multi-az"),
+		Entry("a v2 manifest from cf-mysql-deployment", "cf-mysql-deployment"),
+		Entry("a v2 manifest with an arbitrator and renamed instance groups", "v2-arbitrator-static-ips"),
+	)
+
+	It("finds the arbitrator by its job, whatever the instance group is named", func() {
+		var cfg helpers.MysqlIntegrationConfig
+		Expect(json.Unmarshal(generateConfig(filepath.Join("fixtures", "v2-arbitrator-static-ips.yml")), &cfg)).To(Succeed())
+
+		Expect(cfg.Arbitrators).To(Equal([]helpers.Component{{Ip: "10.0.16.12"}}))
+		Expect(cfg.MysqlNodes).To(Equal([]helpers.Component{{Ip: "10.0.16.10"}, {Ip: "10.0.16.11"}}))
+	})
+
+	Describe("loading the generated configuration", func() {
+		var originalConfigEnv string
+
+		BeforeEach(func() {
+			originalConfigEnv = os.Getenv("CONFIG")
+		})
+
+		AfterEach(func() {
+			os.Setenv("CONFIG", originalConfigEnv)
+		})
+
+		DescribeTable("reads back into an identical, valid configuration",
+			func(fixture string) {
+				generatedConfig := generateConfig(filepath.Join("fixtures", fixture+".yml"))
+
+				configPath := filepath.Join(tmpDir, "integration_config.json")
+				err := ioutil.WriteFile(configPath, generatedConfig, 0644)
+				Expect(err).NotTo(HaveOccurred())
+
+				os.Setenv("CONFIG", configPath)
+				loadedConfig, err := helpers.LoadConfig()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(helpers.ValidateConfig(&loadedConfig)).To(Succeed())
+
+				reencodedConfig, err := json.Marshal(loadedConfig)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(reencodedConfig).To(MatchJSON(generatedConfig))
+			},
+			Entry("from a v1 manifest", "v1-multi-az"),
+			Entry("from a v2 manifest", "cf-mysql-deployment"),
+			Entry("from a v2 manifest with several services", "v2-arbitrator-static-ips"),
+		)
+	})
+
+	Describe("resolving ((variables))", func() {
+		var (
+			placeholderManifest string
+			expectedConfig      []byte
+		)
+
+		BeforeEach(func() {
+			placeholderManifest = filepath.Join("fixtures", "cf-mysql-deployment-placeholders.yml")
+
+			var err error
+			expectedConfig, err = ioutil.ReadFile(filepath.Join("fixtures", "cf-mysql-deployment.json"))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("interpolates values from a vars-store and vars-files", func() {
+			generatedConfig := generateConfig(placeholderManifest,
+				"-vars-store", filepath.Join("fixtures", "vars-store.yml"),
+				"-vars-file", filepath.Join("fixtures", "vars-file.yml"),
+			)
+
+			Expect(generatedConfig).To(MatchJSON(expectedConfig))
+		})
+
+		It("interpolates values from a CredHub export", func() {
+			generatedConfig := generateConfig(placeholderManifest,
+				"-credhub-export", filepath.Join("fixtures", "credhub-export.json"),
+				"-vars-file", filepath.Join("fixtures", "vars-file.yml"),
+			)
+
+			Expect(generatedConfig).To(MatchJSON(expectedConfig))
+		})
+
+		It("lists every variable that is still unresolved", func() {
+			sess, stdOut := runGenerator(placeholderManifest,
+				"-vars-file", filepath.Join("fixtures", "vars-file.yml"),
+			)
+
+			Expect(sess.ExitCode()).To(Equal(1))
+			Expect(stdOut.Len()).To(BeZero())
+			Expect(sess.Err).To(gbytes.Say("Expected to find variables:"))
+			Expect(sess.Err).To(gbytes.Say("- cf_mysql_mysql_admin_password"))
+			Expect(sess.Err).To(gbytes.Say("- cf_mysql_proxy_api_password"))
+			Expect(sess.Err).To(gbytes.Say("- cf_mysql_smoke_tests_password"))
+			Expect(sess.Err).NotTo(gbytes.Say("cluster_health"))
+		})
+	})
+
+	Describe("fetching the manifest from a BOSH director", func() {
+		var (
+			director       *httptest.Server
+			mu             sync.Mutex
+			rejectClient   bool
+			caCertPath     string
+			deploymentArgs []string
+		)
+
+		BeforeEach(func() {
+			manifest, err := ioutil.ReadFile(filepath.Join("fixtures", "cf-mysql-deployment.yml"))
+			Expect(err).NotTo(HaveOccurred())
+
+			rejectClient = false
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				reject := rejectClient
+				mu.Unlock()
+
+				client, secret, ok := r.BasicAuth()
+				if reject || !ok || client != "admin" || secret != "bosh-secret" {
+					w.WriteHeader(http.StatusUnauthorized)
+					w.Write([]byte(`{"error": "unauthorized"}`))
+					return
+				}
+
+				w.Write([]byte(`{"token_type": "bearer", "access_token": "director-token"}`))
+			})
+			mux.HandleFunc("/deployments/cf-mysql", func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "bearer director-token" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+
+				json.NewEncoder(w).Encode(map[string]string{"manifest": string(manifest)})
+			})
+			mux.HandleFunc("/deployments/cf-mysql/instances", func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "bearer director-token" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+
+				w.Write([]byte(`[
+					{"id": "mysql-0", "job": "mysql", "ips": ["10.0.0.10"]},
+					{"id": "proxy-0", "job": "proxy", "ips": ["10.0.0.20"]},
+					{"id": "mysql-1", "job": "mysql", "ips": ["10.0.0.11"]},
+					{"id": "broker-0", "job": "broker", "ips": ["10.0.0.30"]},
+					{"id": "proxy-1", "job": "proxy", "ips": ["10.0.0.21"]},
+					{"id": "mysql-2", "job": "mysql", "ips": ["10.0.0.12"]},
+					{"id": "broker-1", "job": "broker", "ips": ["10.0.0.31"]},
+					{"id": "smoke-tests-0", "job": "smoke-tests", "ips": []}
+				]`))
+			})
+
+			director = httptest.NewTLSServer(mux)
+
+			caCert := pem.EncodeToMemory(&pem.Block{
+				Type:  "CERTIFICATE",
+				Bytes: director.Certificate().Raw,
+			})
+			caCertPath = filepath.Join(tmpDir, "director-ca.pem")
+			Expect(ioutil.WriteFile(caCertPath, caCert, 0644)).To(Succeed())
+
+			deploymentArgs = []string{
+				"-deployment", "cf-mysql",
+				"-boshURL", director.URL,
+				"-boshUAAURL", director.URL,
+				"-boshCACertPath", caCertPath,
+			}
+		})
+
+		AfterEach(func() {
+			director.Close()
+		})
+
+		It("fills the nodes, brokers and proxies from the deployed instances", func() {
+			expectedConfig, err := ioutil.ReadFile(filepath.Join("fixtures", "cf-mysql-deployment-director.json"))
+			Expect(err).NotTo(HaveOccurred())
+
+			generatedConfig := generateConfig("", deploymentArgs...)
+
+			var cfg map[string]interface{}
+			Expect(json.Unmarshal(generatedConfig, &cfg)).To(Succeed())
+			Expect(cfg["bosh"]).To(HaveKeyWithValue("url", director.URL))
+			delete(cfg, "bosh")
+
+			Expect(json.Marshal(cfg)).To(MatchJSON(expectedConfig))
+		})
+
+		It("fails when the director rejects the client credentials", func() {
+			mu.Lock()
+			rejectClient = true
+			mu.Unlock()
+
+			sess, stdOut := runGenerator("", deploymentArgs...)
+			Expect(sess.ExitCode()).NotTo(Equal(0))
+			Expect(stdOut.Len()).To(BeZero())
+		})
+	})
+
+	It("fails when the manifest does not define any broker services", func() {
 		manifest := `---
-jobs:
-- name: proxy_z1
-  instances: 1
-- name: proxy_z2
+instance_groups:
+- name: mysql
   instances: 1
-- name: proxy_z3
-  instances: 1
-properties:
-  cf:
-    api_url: https://api.bosh-lite.com
-    app_domains:
-    - example.com
-    - bosh-lite.com
-    admin_username: admin
-    admin_password: foobar
-    smoke_tests:
-      use_existing_org: true
-      org: system
-    skip_ssl_validation: true
-  cf_mysql:
-    host: bosh-lite.com
-    external_host: p-mysql.bosh-lite.com
-    smoke_tests:
-      password: meowth
-      timeout_scale: 2.0
-    broker:
-      services:
-      - name: p-mysql
-        max_user_connections_default: 40
-        plans:
-        - name: 10mb
-          private: true
-          max_storage_mb: 10
-          max_user_connections: 20
-        - name: 100mb
-          max_storage_mb: 100
-    mysql:
-      port: 5432
-      admin_username: admin
-      admin_password: password
-    proxy:
-      api_username: admin
-      api_password: barfoo
-      api_force_https: true`
+  jobs:
+  - name: mysql
+    release: cf-mysql`
 
 		err := ioutil.WriteFile(manifestPath, []byte(manifest), 0644)
 		Expect(err).NotTo(HaveOccurred())
@@ -131,18 +291,12 @@ properties:
 			"-manifestPath", manifestPath,
 		)
 
-		var (
-			stdOut bytes.Buffer
-		)
-
-		sess, err := gexec.Start(configureCmd, &stdOut, GinkgoWriter)
+		sess, err := gexec.Start(configureCmd, GinkgoWriter, GinkgoWriter)
 		Expect(err).NotTo(HaveOccurred())
 
 		<-sess.Exited
 
-		Expect(sess.ExitCode()).To(Equal(0))
-
-		Expect(ioutil.ReadAll(&stdOut)).To(MatchJSON(config))
-
+		Expect(sess.ExitCode()).NotTo(Equal(0))
+		Expect(sess.Err).To(gbytes.Say("cf_mysql.broker.services"))
 	})
 })
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	mysqlJobName      = "mysql"
+	proxyJobName      = "proxy"
+	brokerJobName     = "cf-mysql-broker"
+	arbitratorJobName = "arbitrator"
+)
+
+func groupsRunning(manifest helpers.MysqlManifest, jobName string) []helpers.InstanceGroup {
+	var groups []helpers.InstanceGroup
+	for _, group := range manifest.Groups() {
+		if group.HasJob(jobName) {
+			groups = append(groups, group)
+		}
+	}
+
+	return groups
+}
+
+// mergedProperties flattens the global properties and the properties of every
+// job in the manifest into a single view. Job-level properties take precedence
+// over global ones, matching how the director renders job templates.
+func mergedProperties(manifest helpers.MysqlManifest) (helpers.Properties, error) {
+	var properties helpers.Properties
+
+	merged := map[interface{}]interface{}{}
+	mergeMaps(merged, manifest.Properties)
+
+	for _, group := range manifest.Groups() {
+		for _, job := range group.Jobs {
+			mergeMaps(merged, job.Properties)
+		}
+	}
+
+	b, err := yaml.Marshal(merged)
+	if err != nil {
+		return properties, err
+	}
+
+	if err := yaml.Unmarshal(b, &properties); err != nil {
+		return properties, fmt.Errorf("Parsing manifest properties: %s", err.Error())
+	}
+
+	return properties, nil
+}
+
+func mergeMaps(dst, src map[interface{}]interface{}) {
+	for key, value := range src {
+		srcMap, srcIsMap := value.(map[interface{}]interface{})
+		dstMap, dstIsMap := dst[key].(map[interface{}]interface{})
+
+		if srcIsMap && dstIsMap {
+			mergeMaps(dstMap, srcMap)
+			continue
+		}
+
+		if srcIsMap {
+			copied := map[interface{}]interface{}{}
+			mergeMaps(copied, srcMap)
+			value = copied
+		}
+
+		dst[key] = value
+	}
+}
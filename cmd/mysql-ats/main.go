@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
+)
+
+const usageExitCode = 2
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage:
+  mysql-ats run [flags] [group|suite ...] [-- ginkgo flags]
+  mysql-ats list [flags] [group|suite ...]
+  mysql-ats doctor [flags]
+  mysql-ats merge-reports [-output dir] [dir ...]
+  mysql-ats sweep [-config file] [-olderThan duration] [-dryRun]
+
+'run' runs the selected suites with ginkgo; 'list' prints the specs each of
+them would run with the given config; 'doctor' checks that every endpoint in
+the config is reachable with its credentials; 'merge-reports' combines the
+JUnit and JSON reports of every suite and parallel node; 'sweep' deletes
+the orgs, apps, service instances and keys that earlier runs leaked.
+Without any group or suite, the '%s' group is selected.
+
+Groups:
+`, defaultGroup)
+
+	for _, name := range groupNames() {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", name, strings.Join(suiteGroups[name], ", "))
+	}
+
+	fmt.Fprintln(os.Stderr, "\nFlags:")
+	var opts options
+	flags := flag.NewFlagSet("mysql-ats", flag.ContinueOnError)
+	opts.register(flags)
+	flags.SetOutput(os.Stderr)
+	flags.PrintDefaults()
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(usageExitCode)
+	}
+
+	switch os.Args[1] {
+	case "run":
+		os.Exit(runCommand(os.Args[2:]))
+	case "list":
+		os.Exit(listCommand(os.Args[2:]))
+	case "doctor":
+		os.Exit(doctorCommand(os.Args[2:]))
+	case "merge-reports":
+		os.Exit(mergeReportsCommand(os.Args[2:]))
+	case "sweep":
+		os.Exit(sweepCommand(os.Args[2:]))
+	case "help", "-h", "-help", "--help":
+		usage()
+		os.Exit(0)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command '%s'\n\n", os.Args[1])
+		usage()
+		os.Exit(usageExitCode)
+	}
+}
+
+// parseArgs accepts flags before and after the group and suite names.
+// Anything after '--' is handed to ginkgo as is.
+func parseArgs(command string, args []string) (options, []string, []string, error) {
+	var (
+		opts        options
+		names       []string
+		passthrough []string
+	)
+
+	for i, arg := range args {
+		if arg == "--" {
+			args, passthrough = args[:i], args[i+1:]
+			break
+		}
+	}
+
+	flags := flag.NewFlagSet("mysql-ats "+command, flag.ContinueOnError)
+	opts.register(flags)
+
+	for {
+		if err := flags.Parse(args); err != nil {
+			return opts, nil, nil, err
+		}
+
+		args = flags.Args()
+		if len(args) == 0 {
+			break
+		}
+
+		names = append(names, args[0])
+		args = args[1:]
+	}
+
+	return opts, names, passthrough, nil
+}
+
+func selectPaths(opts options, names []string) ([]string, []string, error) {
+	selected, err := selectSuites(names, opts.include, opts.exclude)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var paths []string
+	for _, suite := range selected {
+		path, err := suitePath(opts.testDir, suite)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		paths = append(paths, path)
+	}
+
+	return selected, paths, nil
+}
+
+// prepareEnvironment exports the settings the suites read and loads the
+// config once, so that a broken config is reported a single time with
+// ConfigErrorExitCode rather than as a failure of every suite.
+func prepareEnvironment(opts options) (helpers.MysqlIntegrationConfig, error) {
+	if opts.configPath != "" {
+		os.Setenv("CONFIG", opts.configPath)
+	}
+
+	for name, value := range map[string]string{"CF_COLOR": "false", "CF_VERBOSE_OUTPUT": "true"} {
+		if _, set := os.LookupEnv(name); !set {
+			os.Setenv(name, value)
+		}
+	}
+
+	return helpers.LoadConfig()
+}
+
+func printConfigError(err error) {
+	fmt.Fprintf(os.Stderr, "Loading config failed:\n  %s\n", strings.Replace(err.Error(), "\n", "\n  ", -1))
+}
+
+func runCommand(args []string) int {
+	opts, names, passthrough, err := parseArgs("run", args)
+	if err != nil {
+		return usageExitCode
+	}
+
+	_, paths, err := selectPaths(opts, names)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return usageExitCode
+	}
+
+	cfg, err := prepareEnvironment(opts)
+	if err != nil {
+		printConfigError(err)
+		return helpers.ConfigErrorExitCode
+	}
+
+	if opts.preflight {
+		results := helpers.Preflight(cfg)
+		helpers.WriteCheckResults(os.Stdout, results)
+
+		if !helpers.PreflightPassed(results) {
+			return helpers.PreflightExitCode
+		}
+	}
+
+	ginkgoArgs := append(append(opts.ginkgoArgs(), passthrough...), paths...)
+
+	cmd := exec.Command(opts.ginkgoPath, ginkgoArgs...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode()
+		}
+
+		fmt.Fprintf(os.Stderr, "Running ginkgo: %s\n", err.Error())
+		return 1
+	}
+
+	return 0
+}
+
+func listCommand(args []string) int {
+	opts, names, passthrough, err := parseArgs("list", args)
+	if err != nil {
+		return usageExitCode
+	}
+
+	if len(passthrough) > 0 {
+		fmt.Fprintln(os.Stderr, "'list' does not take ginkgo flags")
+		return usageExitCode
+	}
+
+	selected, paths, err := selectPaths(opts, names)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return usageExitCode
+	}
+
+	if _, err := prepareEnvironment(opts); err != nil {
+		printConfigError(err)
+		return helpers.ConfigErrorExitCode
+	}
+
+	exitCode := 0
+	for i, suite := range selected {
+		specs, output, err := listSpecs(opts, paths[i])
+		if err != nil {
+			fmt.Printf("%s: not runnable with this config\n", suite)
+			for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+				fmt.Printf("    %s\n", line)
+			}
+
+			exitCode = 1
+			continue
+		}
+
+		fmt.Printf("%s: %d specs\n", suite, len(specs))
+		for _, spec := range specs {
+			fmt.Printf("    %s\n", spec)
+		}
+	}
+
+	return exitCode
+}
+
+func doctorCommand(args []string) int {
+	opts, names, passthrough, err := parseArgs("doctor", args)
+	if err != nil {
+		return usageExitCode
+	}
+
+	if len(names) > 0 || len(passthrough) > 0 {
+		fmt.Fprintln(os.Stderr, "'doctor' checks the whole config and does not take suites or ginkgo flags")
+		return usageExitCode
+	}
+
+	cfg, err := prepareEnvironment(opts)
+	if err != nil {
+		printConfigError(err)
+		return helpers.ConfigErrorExitCode
+	}
+
+	results := helpers.Preflight(cfg)
+	helpers.WriteCheckResults(os.Stdout, results)
+
+	if !helpers.PreflightPassed(results) {
+		return helpers.PreflightExitCode
+	}
+
+	return 0
+}
+
+func sweepCommand(args []string) int {
+	var opts options
+
+	flags := flag.NewFlagSet("mysql-ats sweep", flag.ContinueOnError)
+	flags.StringVar(&opts.configPath, "config", "", "Path to the integration config (defaults to $CONFIG)")
+	olderThan := flags.Duration("olderThan", 24*time.Hour, "Only delete resources created longer ago than this")
+	dryRun := flags.Bool("dryRun", false, "List the leaked resources without deleting them")
+	if err := flags.Parse(args); err != nil {
+		return usageExitCode
+	}
+
+	if flags.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "'sweep' does not take suites or groups")
+		return usageExitCode
+	}
+
+	cfg, err := prepareEnvironment(opts)
+	if err != nil {
+		printConfigError(err)
+		return helpers.ConfigErrorExitCode
+	}
+
+	if err := helpers.Sweep(os.Stdout, cfg.CFConfig, *olderThan, *dryRun); err != nil {
+		fmt.Fprintf(os.Stderr, "Sweeping leaked resources failed: %s\n", err.Error())
+		return 1
+	}
+
+	return 0
+}
+
+// listSpecs dry-runs a suite and returns the sorted text of every spec it
+// would run. When the suite cannot run, its output is returned instead.
+func listSpecs(opts options, path string) ([]string, []byte, error) {
+	specList, err := ioutil.TempFile("", "mysql-ats-specs")
+	if err != nil {
+		return nil, nil, err
+	}
+	specList.Close()
+	defer os.Remove(specList.Name())
+
+	args := append([]string{"-dryRun", "-noColor", "-succinct"}, opts.filterArgs()...)
+
+	cmd := exec.Command(opts.ginkgoPath, append(args, path)...)
+	cmd.Env = append(os.Environ(), helpers.SpecListEnv+"="+specList.Name())
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, output, err
+	}
+
+	f, err := os.Open(specList.Name())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	var specs []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		specs = append(specs, scanner.Text())
+	}
+
+	sort.Strings(specs)
+	return specs, nil, scanner.Err()
+}
@@ -0,0 +1,384 @@
+package main_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("mysql-ats", func() {
+	var (
+		tmpDir     string
+		testDir    string
+		configPath string
+		env        []string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "mysql-ats")
+		Expect(err).NotTo(HaveOccurred())
+
+		testDir = filepath.Join(tmpDir, "cf-mysql-service")
+		for _, suite := range []string{"broker", "dashboard", "failover", "lifecycle", "osb", "proxy", "quota", "standalone", "tuning"} {
+			Expect(os.MkdirAll(filepath.Join(testDir, suite), 0755)).To(Succeed())
+		}
+
+		configPath = filepath.Join(tmpDir, "integration_config.json")
+		Expect(ioutil.WriteFile(configPath, []byte(`{
+  "api": "api.bosh-lite.com",
+  "admin_user": "admin",
+  "admin_password": "admin"
+}`), 0644)).To(Succeed())
+
+		env = nil
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	mysqlATS := func(args ...string) (*gexec.Session, *bytes.Buffer) {
+		fakeGinkgo, err := filepath.Abs(filepath.Join("fixtures", "fake-ginkgo"))
+		Expect(err).NotTo(HaveOccurred())
+
+		args = append([]string{args[0], "-ginkgo", fakeGinkgo, "-testDir", testDir, "-config", configPath}, args[1:]...)
+
+		cmd := exec.Command(binPath, args...)
+		cmd.Env = append(os.Environ(), env...)
+
+		var stdOut bytes.Buffer
+		sess, err := gexec.Start(cmd, &stdOut, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+
+		<-sess.Exited
+
+		return sess, &stdOut
+	}
+
+	suiteDir := func(suite string) string {
+		return filepath.Join(testDir, suite)
+	}
+
+	Describe("run", func() {
+		It("runs the acceptance group with the default ginkgo options", func() {
+			sess, stdOut := mysqlATS("run")
+			Expect(sess.ExitCode()).To(Equal(0))
+
+			Expect(stdOut.String()).To(ContainSubstring("ARGS: -p -v=true -keepGoing=true -randomizeSuites=true -randomizeAllSpecs=true -trace=true -slowSpecThreshold=300 -failOnPending=true " +
+				suiteDir("broker") + " " + suiteDir("dashboard") + " " + suiteDir("lifecycle") + " " + suiteDir("proxy") + " " + suiteDir("quota") + "\n"))
+			Expect(stdOut.String()).To(ContainSubstring("CONFIG: " + configPath))
+			Expect(stdOut.String()).To(ContainSubstring("CF_COLOR: false"))
+		})
+
+		It("runs the named groups and suites", func() {
+			sess, stdOut := mysqlATS("run", "failover", "tuning")
+			Expect(sess.ExitCode()).To(Equal(0))
+
+			Expect(stdOut.String()).To(ContainSubstring(" " + suiteDir("failover") + " " + suiteDir("tuning") + "\n"))
+		})
+
+		It("applies the include and exclude filters", func() {
+			sess, stdOut := mysqlATS("run", "smoke", "-include", "standalone", "-exclude", "lifecycle,proxy")
+			Expect(sess.ExitCode()).To(Equal(0))
+
+			Expect(stdOut.String()).To(ContainSubstring(" " + suiteDir("broker") + " " + suiteDir("standalone") + "\n"))
+		})
+
+		It("passes parallelism, randomisation and spec filters to ginkgo", func() {
+			sess, stdOut := mysqlATS("run", "-nodes", "3", "-randomizeAllSpecs=false", "-seed", "42", "-focus", "quota", "proxy", "--", "-dryRun")
+			Expect(sess.ExitCode()).To(Equal(0))
+
+			Expect(stdOut.String()).To(ContainSubstring("ARGS: -nodes=3 -v=true -keepGoing=true -randomizeSuites=true -randomizeAllSpecs=false -trace=true -slowSpecThreshold=300 -failOnPending=true -seed=42 -focus=quota -dryRun " + suiteDir("proxy") + "\n"))
+		})
+
+		It("prefers precompiled suites", func() {
+			compiledSuite := filepath.Join(suiteDir("proxy"), "proxy.test")
+			Expect(ioutil.WriteFile(compiledSuite, nil, 0755)).To(Succeed())
+
+			sess, stdOut := mysqlATS("run", "proxy")
+			Expect(sess.ExitCode()).To(Equal(0))
+
+			Expect(stdOut.String()).To(ContainSubstring(" " + compiledSuite + "\n"))
+		})
+
+		It("exits with ginkgo's exit code", func() {
+			env = append(env, "FAKE_GINKGO_EXIT_CODE=3")
+
+			sess, _ := mysqlATS("run")
+			Expect(sess.ExitCode()).To(Equal(3))
+		})
+
+		It("rejects unknown groups and suites", func() {
+			sess, stdOut := mysqlATS("run", "everything")
+			Expect(sess.ExitCode()).To(Equal(2))
+			Expect(sess.Err).To(gbytes.Say("Unknown suite or group 'everything'"))
+			Expect(stdOut.Len()).To(BeZero())
+		})
+
+		It("does not start ginkgo when the config cannot be loaded", func() {
+			Expect(ioutil.WriteFile(configPath, []byte(`{"api": `), 0644)).To(Succeed())
+
+			sess, stdOut := mysqlATS("run")
+			Expect(sess.ExitCode()).To(Equal(78))
+			Expect(sess.Err).To(gbytes.Say("Loading config failed"))
+			Expect(stdOut.Len()).To(BeZero())
+		})
+	})
+
+	Describe("list", func() {
+		It("prints the specs each suite would run", func() {
+			sess, stdOut := mysqlATS("list", "smoke", "-exclude", "lifecycle")
+			Expect(sess.ExitCode()).To(Equal(0))
+
+			Expect(stdOut.String()).To(Equal(`broker: 2 specs
+    broker first spec
+    broker second spec
+proxy: 2 specs
+    proxy first spec
+    proxy second spec
+`))
+		})
+
+		It("reports suites that cannot run with the config", func() {
+			Expect(ioutil.WriteFile(filepath.Join(suiteDir("proxy"), "not-runnable"), nil, 0644)).To(Succeed())
+
+			sess, stdOut := mysqlATS("list", "proxy", "broker")
+			Expect(sess.ExitCode()).To(Equal(1))
+
+			Expect(stdOut.String()).To(ContainSubstring("broker: 2 specs"))
+			Expect(stdOut.String()).To(ContainSubstring("proxy: not runnable with this config\n"))
+			Expect(stdOut.String()).To(ContainSubstring("    Validating config failed:\n      Field 'proxy.api_password' must not be empty"))
+		})
+	})
+
+	Describe("doctor", func() {
+		var cfServer *httptest.Server
+
+		BeforeEach(func() {
+			cfServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/v2/info":
+					fmt.Fprintf(w, `{"token_endpoint": "%s"}`, cfServer.URL)
+				case "/oauth/token":
+					if r.PostFormValue("password") != "admin" {
+						w.WriteHeader(http.StatusUnauthorized)
+						return
+					}
+
+					fmt.Fprint(w, `{"access_token": "token"}`)
+				}
+			}))
+		})
+
+		AfterEach(func() {
+			cfServer.Close()
+		})
+
+		writeConfig := func(adminPassword string) {
+			Expect(ioutil.WriteFile(configPath, []byte(fmt.Sprintf(`{
+  "api": "%s",
+  "admin_user": "admin",
+  "admin_password": "%s",
+  "broker_host": "p-mysql.bosh-lite.com"
+}`, cfServer.URL, adminPassword)), 0644)).To(Succeed())
+		}
+
+		It("prints a table of the checks", func() {
+			writeConfig("admin")
+
+			sess, stdOut := mysqlATS("doctor")
+			Expect(sess.ExitCode()).To(Equal(0))
+
+			Expect(stdOut.String()).To(MatchRegexp(`CHECK\s+TARGET\s+RESULT\n`))
+			Expect(stdOut.String()).To(MatchRegexp(`CF API login\s+` + cfServer.URL + `\s+pass\n`))
+			Expect(stdOut.String()).To(MatchRegexp(`Broker catalog\s+https://p-mysql.bosh-lite.com/v2/catalog\s+skipped: broker_username and broker_password are not set\n`))
+		})
+
+		It("exits with EX_UNAVAILABLE when a check fails", func() {
+			writeConfig("wrong")
+
+			sess, stdOut := mysqlATS("doctor")
+			Expect(sess.ExitCode()).To(Equal(69))
+			Expect(stdOut.String()).To(ContainSubstring("FAIL: UAA did not log in 'admin': 401 Unauthorized"))
+		})
+
+		It("runs the checks before the suites with -preflight", func() {
+			writeConfig("wrong")
+
+			sess, stdOut := mysqlATS("run", "-preflight")
+			Expect(sess.ExitCode()).To(Equal(69))
+			Expect(stdOut.String()).To(ContainSubstring("FAIL: UAA did not log in"))
+			Expect(stdOut.String()).NotTo(ContainSubstring("ARGS:"))
+		})
+	})
+
+	Describe("merge-reports", func() {
+		mergeReports := func(args ...string) *gexec.Session {
+			cmd := exec.Command(binPath, append([]string{"merge-reports", "-testDir", testDir}, args...)...)
+
+			sess, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			<-sess.Exited
+			return sess
+		}
+
+		writeReport := func(suite, name, contents string) {
+			Expect(ioutil.WriteFile(filepath.Join(suiteDir(suite), name), []byte(contents), 0644)).To(Succeed())
+		}
+
+		junit := func(class, name string, failed bool, time float64) string {
+			failure := ""
+			failures := 0
+			if failed {
+				failure = `<failure type="Failure">boom</failure>`
+				failures = 1
+			}
+
+			return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<testsuite tests="1" failures="%d" time="%g"><testcase name="%s" classname="%s" time="%g">%s</testcase></testsuite>`,
+				failures, time, name, class, time, failure)
+		}
+
+		report := func(suite, fingerprint, started, spec string, duration float64) string {
+			return fmt.Sprintf(`{"suite": "%s", "config_fingerprint": "%s", "started_at": "%s", "duration_seconds": %g,
+  "specs": [{"text": "%s", "state": "passed", "started_at": "%s", "steps": [], "cf_commands": []}]}`,
+				suite, fingerprint, started, duration, spec, started)
+		}
+
+		It("merges the reports of every suite and node", func() {
+			writeReport("lifecycle", "junit_1.xml", junit("lifecycle", "second", false, 2))
+			writeReport("lifecycle", "junit_2.xml", junit("lifecycle", "first", true, 5))
+			writeReport("quota", "junit_1.xml", junit("quota", "only", false, 1))
+			writeReport("lifecycle", "report_1.json", report("lifecycle", "abc", "2018-01-01T10:00:05Z", "second", 2))
+			writeReport("lifecycle", "report_2.json", report("lifecycle", "abc", "2018-01-01T10:00:00Z", "first", 5))
+			writeReport("quota", "report_1.json", report("quota", "abc", "2018-01-01T10:01:00Z", "only", 1))
+
+			outputDir := filepath.Join(tmpDir, "merged")
+			Expect(os.Mkdir(outputDir, 0755)).To(Succeed())
+
+			sess := mergeReports("-output", outputDir)
+			Expect(sess.ExitCode()).To(Equal(0))
+			Expect(sess.Out).To(gbytes.Say("Merged 3 JUnit and 3 JSON reports"))
+
+			junitXML, err := ioutil.ReadFile(filepath.Join(outputDir, "junit.xml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(junitXML)).To(ContainSubstring(`<testsuites tests="3" failures="1" time="6">`))
+			Expect(string(junitXML)).To(ContainSubstring(`<testsuite name="lifecycle" tests="2" failures="1" time="5">`))
+			Expect(string(junitXML)).To(ContainSubstring(`<testsuite name="quota" tests="1" failures="0" time="1">`))
+
+			reportJSON, err := ioutil.ReadFile(filepath.Join(outputDir, "report.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reportJSON).To(MatchJSON(`{
+  "config_fingerprints": ["abc"],
+  "suites": [
+    {
+      "suite": "lifecycle",
+      "nodes": 2,
+      "started_at": "2018-01-01T10:00:00Z",
+      "duration_seconds": 5,
+      "specs": [
+        {"text": "first", "state": "passed", "started_at": "2018-01-01T10:00:00Z", "duration_seconds": 0, "steps": [], "cf_commands": []},
+        {"text": "second", "state": "passed", "started_at": "2018-01-01T10:00:05Z", "duration_seconds": 0, "steps": [], "cf_commands": []}
+      ]
+    },
+    {
+      "suite": "quota",
+      "nodes": 1,
+      "started_at": "2018-01-01T10:01:00Z",
+      "duration_seconds": 1,
+      "specs": [
+        {"text": "only", "state": "passed", "started_at": "2018-01-01T10:01:00Z", "duration_seconds": 0, "steps": [], "cf_commands": []}
+      ]
+    }
+  ]
+}`))
+		})
+
+		It("warns when the reports come from different configs", func() {
+			writeReport("lifecycle", "report_1.json", report("lifecycle", "abc", "2018-01-01T10:00:00Z", "first", 1))
+			writeReport("quota", "report_1.json", report("quota", "def", "2018-01-01T10:00:00Z", "only", 1))
+
+			sess := mergeReports("-output", tmpDir)
+			Expect(sess.ExitCode()).To(Equal(0))
+			Expect(sess.Err).To(gbytes.Say("produced with 2 different configs"))
+		})
+
+		It("fails when there is nothing to merge", func() {
+			sess := mergeReports("-output", tmpDir)
+			Expect(sess.ExitCode()).To(Equal(1))
+			Expect(sess.Err).To(gbytes.Say("No junit_N.xml or report_N.json files found"))
+		})
+	})
+
+	Describe("sweep", func() {
+		var (
+			cfServer *httptest.Server
+			deleted  []string
+		)
+
+		BeforeEach(func() {
+			deleted = nil
+			cfServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.URL.Path == "/v2/info":
+					fmt.Fprintf(w, `{"token_endpoint": "%s"}`, cfServer.URL)
+				case r.URL.Path == "/oauth/token":
+					fmt.Fprint(w, `{"access_token": "token"}`)
+				case r.Method == "DELETE":
+					deleted = append(deleted, r.URL.Path)
+					w.WriteHeader(http.StatusNoContent)
+				case r.URL.Path == "/v3/organizations":
+					fmt.Fprint(w, `{"pagination": {"next": null}, "resources": [{"guid": "org-guid", "name": "MySQLATS-1-ORG-abc", "created_at": "2018-01-01T00:00:00Z"}]}`)
+				default:
+					fmt.Fprint(w, `{"pagination": {"next": null}, "resources": []}`)
+				}
+			}))
+
+			Expect(ioutil.WriteFile(configPath, []byte(fmt.Sprintf(`{
+  "api": "%s",
+  "admin_user": "admin",
+  "admin_password": "admin"
+}`, cfServer.URL)), 0644)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			cfServer.Close()
+		})
+
+		sweep := func(args ...string) (*gexec.Session, *bytes.Buffer) {
+			cmd := exec.Command(binPath, append([]string{"sweep", "-config", configPath}, args...)...)
+
+			var stdOut bytes.Buffer
+			sess, err := gexec.Start(cmd, &stdOut, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			<-sess.Exited
+			return sess, &stdOut
+		}
+
+		It("lists leaked resources in a dry run", func() {
+			sess, stdOut := sweep("-dryRun")
+			Expect(sess.ExitCode()).To(Equal(0))
+			Expect(stdOut.String()).To(MatchRegexp(`org\s+MySQLATS-1-ORG-abc\s+\S+\s+would delete\n`))
+			Expect(deleted).To(BeEmpty())
+		})
+
+		It("deletes leaked resources older than the given age", func() {
+			sess, _ := sweep("-olderThan", "1h")
+			Expect(sess.ExitCode()).To(Equal(0))
+			Expect(deleted).To(Equal([]string{"/v3/organizations/org-guid"}))
+		})
+	})
+})
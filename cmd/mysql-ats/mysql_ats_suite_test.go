@@ -0,0 +1,31 @@
+package main_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+
+	"github.com/onsi/gomega/gexec"
+)
+
+var binPath string
+
+var _ = SynchronizedBeforeSuite(func() []byte {
+	binPath, err := gexec.Build("github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/cmd/mysql-ats")
+	Expect(err).NotTo(HaveOccurred())
+
+	return []byte(binPath)
+}, func(data []byte) {
+	binPath = string(data)
+})
+
+var _ = SynchronizedAfterSuite(func() {
+}, func() {
+	gexec.CleanupBuildArtifacts()
+})
+
+func TestMysqlATS(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "mysql-ats Command Suite")
+}
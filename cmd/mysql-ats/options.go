@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+)
+
+// options holds the settings shared by `run` and `list`. The ginkgo defaults
+// match the ones the bin/ scripts used to pass.
+type options struct {
+	ginkgoPath string
+	testDir    string
+	configPath string
+	include    suiteList
+	exclude    suiteList
+	focus      string
+	skip       string
+	preflight  bool
+
+	parallel          bool
+	nodes             int
+	randomizeSuites   bool
+	randomizeAllSpecs bool
+	seed              int64
+	keepGoing         bool
+	trace             bool
+	verbose           bool
+	slowSpecThreshold float64
+	failOnPending     bool
+}
+
+func (o *options) register(flags *flag.FlagSet) {
+	flags.StringVar(&o.ginkgoPath, "ginkgo", "ginkgo", "Path to the ginkgo CLI")
+	flags.StringVar(&o.testDir, "testDir", "cf-mysql-service", "Directory containing the suites")
+	flags.StringVar(&o.configPath, "config", "", "Path to the integration config (defaults to $CONFIG)")
+	flags.Var(&o.include, "include", "Comma-separated suites to run in addition to the selected groups")
+	flags.Var(&o.exclude, "exclude", "Comma-separated suites to leave out of the selected groups")
+	flags.StringVar(&o.focus, "focus", "", "Only run specs matching this regular expression")
+	flags.StringVar(&o.skip, "skip", "", "Skip specs matching this regular expression")
+	flags.BoolVar(&o.preflight, "preflight", false, "Check every endpoint in the config before running the suites")
+
+	flags.BoolVar(&o.parallel, "p", true, "Run specs in parallel, with ginkgo picking the number of nodes")
+	flags.IntVar(&o.nodes, "nodes", 0, "Number of parallel nodes (overrides -p)")
+	flags.BoolVar(&o.randomizeSuites, "randomizeSuites", true, "Run the suites in a random order")
+	flags.BoolVar(&o.randomizeAllSpecs, "randomizeAllSpecs", true, "Shuffle specs across containers, not just top-level containers")
+	flags.Int64Var(&o.seed, "seed", 0, "Seed for randomisation (0 lets ginkgo pick one)")
+	flags.BoolVar(&o.keepGoing, "keepGoing", true, "Keep running suites after one fails")
+	flags.BoolVar(&o.trace, "trace", true, "Print the full stack trace of failures")
+	flags.BoolVar(&o.verbose, "v", true, "Print the text of every spec")
+	flags.Float64Var(&o.slowSpecThreshold, "slowSpecThreshold", 300, "Seconds after which a passing spec is reported as slow")
+	flags.BoolVar(&o.failOnPending, "failOnPending", true, "Fail the run if any spec is pending")
+}
+
+// ginkgoArgs returns the ginkgo flags for a real run.
+func (o options) ginkgoArgs() []string {
+	var args []string
+
+	if o.nodes > 0 {
+		args = append(args, fmt.Sprintf("-nodes=%d", o.nodes))
+	} else if o.parallel {
+		args = append(args, "-p")
+	}
+
+	args = append(args,
+		fmt.Sprintf("-v=%t", o.verbose),
+		fmt.Sprintf("-keepGoing=%t", o.keepGoing),
+		fmt.Sprintf("-randomizeSuites=%t", o.randomizeSuites),
+		fmt.Sprintf("-randomizeAllSpecs=%t", o.randomizeAllSpecs),
+		fmt.Sprintf("-trace=%t", o.trace),
+		"-slowSpecThreshold="+strconv.FormatFloat(o.slowSpecThreshold, 'f', -1, 64),
+		fmt.Sprintf("-failOnPending=%t", o.failOnPending),
+	)
+
+	if o.seed != 0 {
+		args = append(args, fmt.Sprintf("-seed=%d", o.seed))
+	}
+
+	return append(args, o.filterArgs()...)
+}
+
+func (o options) filterArgs() []string {
+	var args []string
+
+	if o.focus != "" {
+		args = append(args, "-focus="+o.focus)
+	}
+
+	if o.skip != "" {
+		args = append(args, "-skip="+o.skip)
+	}
+
+	return args
+}
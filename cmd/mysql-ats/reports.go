@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/onsi/ginkgo/reporters"
+
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
+)
+
+var (
+	junitFilePattern  = regexp.MustCompile(`^junit_\d+\.xml$`)
+	reportFilePattern = regexp.MustCompile(`^report_\d+\.json$`)
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     float64          `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	XMLName   xml.Name                  `xml:"testsuite"`
+	Name      string                    `xml:"name,attr"`
+	Tests     int                       `xml:"tests,attr"`
+	Failures  int                       `xml:"failures,attr"`
+	Time      float64                   `xml:"time,attr"`
+	TestCases []reporters.JUnitTestCase `xml:"testcase"`
+}
+
+type mergedReport struct {
+	ConfigFingerprints []string      `json:"config_fingerprints"`
+	Suites             []mergedSuite `json:"suites"`
+}
+
+type mergedSuite struct {
+	Suite           string               `json:"suite"`
+	Nodes           int                  `json:"nodes"`
+	StartedAt       time.Time            `json:"started_at"`
+	DurationSeconds float64              `json:"duration_seconds"`
+	Specs           []helpers.SpecReport `json:"specs"`
+}
+
+func mergeReportsCommand(args []string) int {
+	flags := flag.NewFlagSet("mysql-ats merge-reports", flag.ContinueOnError)
+	testDir := flags.String("testDir", "cf-mysql-service", "Directory to search for the reports of every suite")
+	outputDir := flags.String("output", ".", "Directory to write junit.xml and report.json to")
+	if err := flags.Parse(args); err != nil {
+		return usageExitCode
+	}
+
+	dirs := flags.Args()
+	if len(dirs) == 0 {
+		dirs = []string{*testDir}
+	}
+
+	junitFiles, reportFiles, err := findReports(dirs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Finding reports: %s\n", err.Error())
+		return 1
+	}
+
+	if len(junitFiles) == 0 && len(reportFiles) == 0 {
+		fmt.Fprintf(os.Stderr, "No junit_N.xml or report_N.json files found in %v\n", dirs)
+		return 1
+	}
+
+	junit, err := mergeJUnit(junitFiles)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Merging JUnit reports: %s\n", err.Error())
+		return 1
+	}
+
+	report, err := mergeRunReports(reportFiles)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Merging JSON reports: %s\n", err.Error())
+		return 1
+	}
+
+	if len(report.ConfigFingerprints) > 1 {
+		fmt.Fprintf(os.Stderr, "Warning: the reports were produced with %d different configs\n", len(report.ConfigFingerprints))
+	}
+
+	if leaked := report.cleanupFailures(); leaked > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %d resources could not be cleaned up; `mysql-ats sweep` deletes them\n", leaked)
+	}
+
+	junitXML, err := xml.MarshalIndent(junit, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Encoding junit.xml: %s\n", err.Error())
+		return 1
+	}
+
+	reportJSON, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Encoding report.json: %s\n", err.Error())
+		return 1
+	}
+
+	for name, contents := range map[string][]byte{
+		"junit.xml":   append([]byte(xml.Header), junitXML...),
+		"report.json": reportJSON,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(*outputDir, name), contents, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Writing %s: %s\n", name, err.Error())
+			return 1
+		}
+	}
+
+	fmt.Printf("Merged %d JUnit and %d JSON reports into %s\n", len(junitFiles), len(reportFiles), *outputDir)
+	return 0
+}
+
+func findReports(dirs []string) ([]string, []string, error) {
+	var junitFiles, reportFiles []string
+
+	for _, dir := range dirs {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+
+			switch {
+			case junitFilePattern.MatchString(info.Name()):
+				junitFiles = append(junitFiles, path)
+			case reportFilePattern.MatchString(info.Name()):
+				reportFiles = append(reportFiles, path)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return junitFiles, reportFiles, nil
+}
+
+// mergeJUnit combines the files of each suite's nodes into one testsuite.
+// The nodes run at the same time, so a suite takes as long as its slowest
+// node.
+func mergeJUnit(paths []string) (junitTestSuites, error) {
+	var (
+		merged junitTestSuites
+		index  = map[string]int{}
+	)
+
+	for _, path := range paths {
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			return merged, err
+		}
+
+		var suite reporters.JUnitTestSuite
+		if err := xml.Unmarshal(buf, &suite); err != nil {
+			return merged, fmt.Errorf("%s: %s", path, err.Error())
+		}
+
+		name := filepath.Base(filepath.Dir(path))
+		if len(suite.TestCases) > 0 {
+			name = suite.TestCases[0].ClassName
+		}
+
+		i, found := index[name]
+		if !found {
+			i = len(merged.Suites)
+			index[name] = i
+			merged.Suites = append(merged.Suites, junitTestSuite{Name: name})
+		}
+
+		target := &merged.Suites[i]
+		target.TestCases = append(target.TestCases, suite.TestCases...)
+		target.Tests += suite.Tests
+		target.Failures += suite.Failures
+		if suite.Time > target.Time {
+			target.Time = suite.Time
+		}
+	}
+
+	for _, suite := range merged.Suites {
+		merged.Tests += suite.Tests
+		merged.Failures += suite.Failures
+		merged.Time += suite.Time
+	}
+
+	return merged, nil
+}
+
+func mergeRunReports(paths []string) (mergedReport, error) {
+	merged := mergedReport{ConfigFingerprints: []string{}, Suites: []mergedSuite{}}
+	index := map[string]int{}
+	fingerprints := map[string]bool{}
+
+	for _, path := range paths {
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			return merged, err
+		}
+
+		var report helpers.RunReport
+		if err := json.Unmarshal(buf, &report); err != nil {
+			return merged, fmt.Errorf("%s: %s", path, err.Error())
+		}
+
+		if !fingerprints[report.ConfigFingerprint] {
+			fingerprints[report.ConfigFingerprint] = true
+			merged.ConfigFingerprints = append(merged.ConfigFingerprints, report.ConfigFingerprint)
+		}
+
+		i, found := index[report.Suite]
+		if !found {
+			i = len(merged.Suites)
+			index[report.Suite] = i
+			merged.Suites = append(merged.Suites, mergedSuite{Suite: report.Suite, StartedAt: report.StartedAt})
+		}
+
+		suite := &merged.Suites[i]
+		suite.Nodes++
+		suite.Specs = append(suite.Specs, report.Specs...)
+
+		if report.StartedAt.Before(suite.StartedAt) {
+			suite.StartedAt = report.StartedAt
+		}
+		if report.DurationSeconds > suite.DurationSeconds {
+			suite.DurationSeconds = report.DurationSeconds
+		}
+	}
+
+	for _, suite := range merged.Suites {
+		specs := suite.Specs
+		sort.SliceStable(specs, func(i, j int) bool {
+			return specs[i].StartedAt.Before(specs[j].StartedAt)
+		})
+	}
+
+	return merged, nil
+}
+
+func (r mergedReport) cleanupFailures() int {
+	count := 0
+	for _, suite := range r.Suites {
+		for _, spec := range suite.Specs {
+			count += len(spec.CleanupFailures)
+		}
+	}
+
+	return count
+}
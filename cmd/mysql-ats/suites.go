@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const defaultGroup = "acceptance"
+
+// suites lists every suite under cf-mysql-service, in the order they are run.
+var suites = []string{
+	"broker",
+	"dashboard",
+	"failover",
+	"lifecycle",
+	"osb",
+	"proxy",
+	"quota",
+	"standalone",
+	"tuning",
+}
+
+var suiteGroups = map[string][]string{
+	"acceptance": {"broker", "dashboard", "lifecycle", "proxy", "quota"},
+	"smoke":      {"broker", "lifecycle", "proxy"},
+	"failover":   {"failover"},
+	"standalone": {"standalone"},
+	"tuning":     {"tuning"},
+	"dashboard":  {"dashboard"},
+	"osb":        {"osb"},
+}
+
+type suiteList []string
+
+func (l *suiteList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *suiteList) Set(value string) error {
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			*l = append(*l, name)
+		}
+	}
+
+	return nil
+}
+
+func isSuite(name string) bool {
+	for _, suite := range suites {
+		if suite == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func groupNames() []string {
+	var names []string
+	for name := range suiteGroups {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// selectSuites expands the named groups and suites, adds the included suites
+// and drops the excluded ones. Without any names the acceptance group is run.
+func selectSuites(names, include, exclude []string) ([]string, error) {
+	if len(names) == 0 {
+		names = []string{defaultGroup}
+	}
+
+	selected := map[string]bool{}
+
+	for _, name := range names {
+		if group, found := suiteGroups[name]; found {
+			for _, suite := range group {
+				selected[suite] = true
+			}
+			continue
+		}
+
+		if !isSuite(name) {
+			return nil, fmt.Errorf("Unknown suite or group '%s'. Groups: %s. Suites: %s.", name, strings.Join(groupNames(), ", "), strings.Join(suites, ", "))
+		}
+
+		selected[name] = true
+	}
+
+	for _, name := range include {
+		if !isSuite(name) {
+			return nil, fmt.Errorf("Unknown suite '%s' in -include", name)
+		}
+
+		selected[name] = true
+	}
+
+	for _, name := range exclude {
+		if !isSuite(name) {
+			return nil, fmt.Errorf("Unknown suite '%s' in -exclude", name)
+		}
+
+		delete(selected, name)
+	}
+
+	var result []string
+	for _, suite := range suites {
+		if selected[suite] {
+			result = append(result, suite)
+		}
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("No suites left to run")
+	}
+
+	return result, nil
+}
+
+// suitePath prefers a precompiled <suite>.test binary, as shipped in the
+// acceptance-tests package, over the suite's source directory.
+func suitePath(testDir, suite string) (string, error) {
+	compiled := filepath.Join(testDir, suite, suite+".test")
+	if _, err := os.Stat(compiled); err == nil {
+		return compiled, nil
+	}
+
+	dir := filepath.Join(testDir, suite)
+	if _, err := os.Stat(dir); err != nil {
+		return "", fmt.Errorf("Suite '%s' not found in '%s'", suite, testDir)
+	}
+
+	return dir, nil
+}
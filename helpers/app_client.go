@@ -0,0 +1,131 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultAppRequestTimeout = 60 * time.Second
+	DefaultAppRetries        = 3
+	DefaultAppRetryBackoff   = time.Second
+)
+
+// AppClientOptions configure the clients of the test apps. Zero values,
+// and a nil Retries, are replaced by the defaults.
+type AppClientOptions struct {
+	SkipSSLValidation bool
+
+	// Timeout bounds each attempt of a request.
+	Timeout time.Duration
+
+	// Retries is how often a GET is retried after a connection error or a
+	// 502, 503 or 504 from the router, waiting Backoff before the first
+	// retry and twice as long before each further one. Requests that change
+	// data are never retried. Point it at 0 to turn retries off.
+	Retries *int
+	Backoff time.Duration
+}
+
+// AppHTTPError is a response from a test app with a status other than 200.
+type AppHTTPError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *AppHTTPError) Error() string {
+	return fmt.Sprintf("%s %s: %s - %s", e.Method, e.URL, e.Status, e.Body)
+}
+
+type appClient struct {
+	client  *http.Client
+	options AppClientOptions
+	retries int
+}
+
+func newAppClient(options AppClientOptions) appClient {
+	if options.Timeout == 0 {
+		options.Timeout = DefaultAppRequestTimeout
+	}
+	retries := DefaultAppRetries
+	if options.Retries != nil {
+		retries = *options.Retries
+	}
+	if options.Backoff == 0 {
+		options.Backoff = DefaultAppRetryBackoff
+	}
+
+	// each attempt is bounded by its own context instead
+	client := newHTTPClient(options.SkipSSLValidation, 0)
+
+	return appClient{client: client, options: options, retries: retries}
+}
+
+// do returns the body of a 200 response, or an *AppHTTPError.
+func (c appClient) do(ctx context.Context, method, uri, body string) (string, error) {
+	attempts := 1
+	if method == "GET" {
+		attempts += c.retries
+	}
+
+	backoff := c.options.Backoff
+	for attempt := 1; ; attempt++ {
+		response, retryable, err := c.attempt(ctx, method, uri, body)
+		if err == nil || !retryable || attempt == attempts {
+			return response, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (c appClient) attempt(ctx context.Context, method, uri, body string) (string, bool, error) {
+	attemptCtx, cancel := context.WithTimeout(ctx, c.options.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(attemptCtx, method, uri, strings.NewReader(body))
+	if err != nil {
+		return "", false, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		// the attempt timing out can be retried, the caller's context ending
+		// cannot
+		return "", ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	buf, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", ctx.Err() == nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		retryable := resp.StatusCode == http.StatusBadGateway ||
+			resp.StatusCode == http.StatusServiceUnavailable ||
+			resp.StatusCode == http.StatusGatewayTimeout
+
+		return "", retryable, &AppHTTPError{
+			Method:     method,
+			URL:        uri,
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       string(buf),
+		}
+	}
+
+	return string(buf), false, nil
+}
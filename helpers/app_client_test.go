@@ -0,0 +1,200 @@
+package helpers_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
+)
+
+var _ = Describe("App clients", func() {
+	var (
+		server      *httptest.Server
+		mu          sync.Mutex
+		requests    []string
+		statuses    []int
+		delay       time.Duration
+		connections int
+		options     helpers.AppClientOptions
+	)
+
+	BeforeEach(func() {
+		requests = nil
+		statuses = nil
+		delay = 0
+		connections = 0
+		options = helpers.AppClientOptions{Timeout: time.Second, Backoff: time.Millisecond}
+
+		// answers with the queued statuses, then with 200
+		server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+
+			mu.Lock()
+			requests = append(requests, fmt.Sprintf("%s %s %s", r.Method, r.URL.Path, body))
+			status := http.StatusOK
+			if len(statuses) > 0 {
+				status, statuses = statuses[0], statuses[1:]
+			}
+			mu.Unlock()
+
+			time.Sleep(delay)
+
+			w.WriteHeader(status)
+			switch {
+			case status != http.StatusOK:
+				fmt.Fprint(w, "Error: INSERT command denied to user for table 'data_values'")
+			case r.URL.Path == "/ping":
+				fmt.Fprint(w, "OK")
+			case r.URL.Path == "/ciphers":
+				fmt.Fprint(w, `{"cipher_used": "AES256-SHA256"}`)
+			default:
+				fmt.Fprintf(w, "%s", body)
+			}
+		}))
+		server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				mu.Lock()
+				connections++
+				mu.Unlock()
+			}
+		}
+		server.Start()
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	// the server may still be handling a request the client gave up on
+	recordedRequests := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return append([]string(nil), requests...)
+	}
+
+	openedConnections := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return connections
+	}
+
+	sinatraClient := func() helpers.SinatraAppClient {
+		return helpers.NewSinatraAppClientWithOptions(server.URL, "some-instance", options)
+	}
+
+	It("sets and gets values over one connection", func() {
+		client := sinatraClient()
+
+		msg, err := client.Set("mykey", "myvalue")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(msg).To(Equal("myvalue"))
+
+		_, err = client.Get("mykey")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(client.Ping()).To(Succeed())
+
+		Expect(recordedRequests()).To(Equal([]string{
+			"POST /service/mysql/some-instance/mykey myvalue",
+			"GET /service/mysql/some-instance/mykey ",
+			"GET /ping ",
+		}))
+
+		// the connection is only reused if every body was closed
+		Expect(openedConnections()).To(Equal(1))
+	})
+
+	It("retries a GET that the router could not route", func() {
+		statuses = []int{http.StatusBadGateway, http.StatusServiceUnavailable}
+
+		Expect(sinatraClient().Ping()).To(Succeed())
+		Expect(recordedRequests()).To(HaveLen(3))
+	})
+
+	It("gives up after the configured retries", func() {
+		statuses = []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}
+		retries := 2
+		options.Retries = &retries
+
+		err := sinatraClient().Ping()
+		Expect(err).To(BeAssignableToTypeOf(&helpers.AppHTTPError{}))
+		Expect(err.(*helpers.AppHTTPError).StatusCode).To(Equal(http.StatusBadGateway))
+		Expect(recordedRequests()).To(HaveLen(3))
+	})
+
+	It("makes a single attempt when retries are turned off", func() {
+		statuses = []int{http.StatusBadGateway}
+		retries := 0
+		options.Retries = &retries
+
+		err := sinatraClient().Ping()
+		Expect(err).To(BeAssignableToTypeOf(&helpers.AppHTTPError{}))
+		Expect(recordedRequests()).To(HaveLen(1))
+	})
+
+	It("does not retry requests that change data", func() {
+		statuses = []int{http.StatusBadGateway}
+
+		_, err := sinatraClient().WriteBulkData("10")
+		Expect(err).To(Equal(&helpers.AppHTTPError{
+			Method:     "POST",
+			URL:        server.URL + "/service/mysql/some-instance/write-bulk-data",
+			StatusCode: http.StatusBadGateway,
+			Status:     "502 Bad Gateway",
+			Body:       "Error: INSERT command denied to user for table 'data_values'",
+		}))
+		Expect(recordedRequests()).To(HaveLen(1))
+	})
+
+	It("does not retry errors from the app", func() {
+		statuses = []int{http.StatusInternalServerError}
+
+		_, err := sinatraClient().Get("mykey")
+		Expect(err).To(MatchError(MatchRegexp("Error: (INSERT|UPDATE) command denied .* for table 'data_values'")))
+		Expect(recordedRequests()).To(HaveLen(1))
+	})
+
+	It("times out each attempt of a request", func() {
+		delay = 100 * time.Millisecond
+		options.Timeout = 20 * time.Millisecond
+		retries := 1
+		options.Retries = &retries
+
+		_, err := sinatraClient().Get("mykey")
+		Expect(err).To(MatchError(ContainSubstring("context deadline exceeded")))
+		Expect(recordedRequests()).To(HaveLen(2))
+	})
+
+	It("stops retrying when the context ends", func() {
+		statuses = []int{http.StatusBadGateway, http.StatusBadGateway}
+		options.Backoff = time.Minute
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		err := sinatraClient().PingContext(ctx)
+		Expect(err).To(BeAssignableToTypeOf(&helpers.AppHTTPError{}))
+		Expect(recordedRequests()).To(HaveLen(1))
+	})
+
+	It("reads the cipher the cipher finder app connected with", func() {
+		statuses = []int{http.StatusGatewayTimeout}
+		client := helpers.NewCipherFinderClientWithOptions(server.URL, options)
+
+		Expect(client.Ping()).To(Succeed())
+
+		cipher, err := client.CiphersContext(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cipher).To(Equal("AES256-SHA256"))
+	})
+})
@@ -0,0 +1,72 @@
+package helpers
+
+import (
+	"fmt"
+
+	boshdir "github.com/cloudfoundry/bosh-cli/director"
+	boshuaa "github.com/cloudfoundry/bosh-cli/uaa"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+func (b BOSH) uaaURL() string {
+	if b.UAAURL != "" {
+		return b.UAAURL
+	}
+
+	return fmt.Sprintf("https://%s:8443", b.URL)
+}
+
+// NewBOSHDirector returns a director client which authenticates against the
+// director's UAA with the configured client credentials.
+func NewBOSHDirector(boshConfig BOSH) (boshdir.Director, error) {
+	uaa, err := buildUAA(boshConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildDirector(boshConfig, uaa)
+}
+
+func buildUAA(boshConfig BOSH) (boshuaa.UAA, error) {
+	logger := boshlog.NewLogger(boshlog.LevelError)
+	factory := boshuaa.NewFactory(logger)
+
+	// Build a UAA config from a URL.
+	// HTTPS is required and certificates are always verified.
+	config, err := boshuaa.NewConfigFromURL(boshConfig.uaaURL())
+	if err != nil {
+		return nil, err
+	}
+
+	// Set client credentials for authentication.
+	// Machine level access should typically use a client instead of a particular user.
+	config.Client = boshConfig.Client
+	config.ClientSecret = boshConfig.ClientSecret
+
+	// Configure trusted CA certificates.
+	// If nothing is provided default system certificates are used.
+	config.CACert = boshConfig.CACert
+
+	return factory.New(config)
+}
+
+func buildDirector(boshConfig BOSH, uaa boshuaa.UAA) (boshdir.Director, error) {
+	logger := boshlog.NewLogger(boshlog.LevelError)
+	factory := boshdir.NewFactory(logger)
+
+	// Build a Director config from address-like string.
+	// HTTPS is required and certificates are always verified.
+	config, err := boshdir.NewConfigFromURL(boshConfig.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	// Configure custom trusted CA certificates.
+	// If nothing is provided default system certificates are used.
+	config.CACert = boshConfig.CACert
+
+	// Allow Director to fetch UAA tokens when necessary.
+	config.TokenFunc = boshuaa.NewClientTokenSession(uaa).TokenFunc
+
+	return factory.New(config, boshdir.NewNoopTaskReporter(), boshdir.NewNoopFileReporter())
+}
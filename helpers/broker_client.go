@@ -0,0 +1,269 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const brokerRequestTimeout = 60 * time.Second
+
+// BrokerClient talks to the service broker directly, with the Open Service
+// Broker API v2, instead of through Cloud Controller.
+//
+// The fields are sent with every request; clearing them sends requests
+// without credentials or without the X-Broker-API-Version header.
+type BrokerClient struct {
+	client *http.Client
+	url    string
+
+	Username   string
+	Password   string
+	APIVersion string
+}
+
+// BrokerResponse is the status and body the broker answered with, which
+// the OSB specs assert on as they are.
+type BrokerResponse struct {
+	StatusCode int
+	Body       []byte
+}
+
+// Decode unmarshals the body.
+func (r *BrokerResponse) Decode(into interface{}) error {
+	if err := json.Unmarshal(r.Body, into); err != nil {
+		return fmt.Errorf("Decoding the broker response %q: %s", r.Body, err.Error())
+	}
+
+	return nil
+}
+
+type BrokerCatalog struct {
+	Services []BrokerService `json:"services"`
+}
+
+type BrokerService struct {
+	ID             string                 `json:"id"`
+	Name           string                 `json:"name"`
+	Description    string                 `json:"description"`
+	Bindable       bool                   `json:"bindable"`
+	PlanUpdateable bool                   `json:"plan_updateable"`
+	Tags           []string               `json:"tags"`
+	Metadata       map[string]interface{} `json:"metadata"`
+	Plans          []BrokerPlan           `json:"plans"`
+}
+
+type BrokerPlan struct {
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Free        *bool              `json:"free"`
+	Metadata    BrokerPlanMetadata `json:"metadata"`
+}
+
+// BrokerPlanMetadata is the metadata of a plan, with the limits the broker
+// enforces for its instances.
+type BrokerPlanMetadata struct {
+	DisplayName        string   `json:"displayName"`
+	Bullets            []string `json:"bullets"`
+	MaxStorageMb       int      `json:"max_storage_mb"`
+	MaxUserConnections int      `json:"max_user_connections"`
+}
+
+// Plan returns the service's plan by name.
+func (s BrokerService) Plan(name string) (BrokerPlan, bool) {
+	for _, plan := range s.Plans {
+		if plan.Name == name {
+			return plan, true
+		}
+	}
+
+	return BrokerPlan{}, false
+}
+
+// Service returns the catalog's service by name.
+func (c BrokerCatalog) Service(name string) (BrokerService, bool) {
+	for _, service := range c.Services {
+		if service.Name == name {
+			return service, true
+		}
+	}
+
+	return BrokerService{}, false
+}
+
+type BrokerProvisionRequest struct {
+	ServiceID        string                 `json:"service_id"`
+	PlanID           string                 `json:"plan_id"`
+	OrganizationGUID string                 `json:"organization_guid"`
+	SpaceGUID        string                 `json:"space_guid"`
+	Parameters       map[string]interface{} `json:"parameters,omitempty"`
+}
+
+type BrokerUpdateRequest struct {
+	ServiceID      string                 `json:"service_id"`
+	PlanID         string                 `json:"plan_id,omitempty"`
+	Parameters     map[string]interface{} `json:"parameters,omitempty"`
+	PreviousValues *BrokerPreviousValues  `json:"previous_values,omitempty"`
+}
+
+type BrokerPreviousValues struct {
+	ServiceID string `json:"service_id,omitempty"`
+	PlanID    string `json:"plan_id,omitempty"`
+}
+
+type BrokerBindRequest struct {
+	ServiceID  string                 `json:"service_id"`
+	PlanID     string                 `json:"plan_id"`
+	AppGUID    string                 `json:"app_guid,omitempty"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+type BrokerProvisionResponse struct {
+	DashboardURL string `json:"dashboard_url"`
+	Operation    string `json:"operation"`
+}
+
+type BrokerBindResponse struct {
+	Credentials map[string]interface{} `json:"credentials"`
+}
+
+type BrokerLastOperationResponse struct {
+	State       string `json:"state"`
+	Description string `json:"description"`
+}
+
+// BrokerErrorResponse is the body of a failed request.
+type BrokerErrorResponse struct {
+	Error       string `json:"error"`
+	Description string `json:"description"`
+}
+
+func NewBrokerClient(cfg MysqlIntegrationConfig) *BrokerClient {
+	skipSSLValidation := cfg.CFConfig != nil && cfg.CFConfig.SkipSSLValidation
+
+	return &BrokerClient{
+		client:     newHTTPClient(skipSSLValidation, brokerRequestTimeout),
+		url:        fmt.Sprintf("%s://%s", cfg.BrokerProtocol, cfg.BrokerHost),
+		Username:   cfg.BrokerUsername,
+		Password:   cfg.BrokerPassword,
+		APIVersion: brokerAPIVersion,
+	}
+}
+
+// Catalog fetches and decodes the catalog, failing unless the broker
+// answers 200.
+func (c *BrokerClient) Catalog() (BrokerCatalog, error) {
+	var catalog BrokerCatalog
+
+	resp, err := c.Request("GET", "/v2/catalog", nil)
+	if err != nil {
+		return catalog, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return catalog, fmt.Errorf("GET /v2/catalog returned %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	return catalog, resp.Decode(&catalog)
+}
+
+func (c *BrokerClient) Provision(instanceID string, request BrokerProvisionRequest, acceptsIncomplete bool) (*BrokerResponse, error) {
+	return c.Request("PUT", instancePath(instanceID)+acceptsIncompleteQuery(acceptsIncomplete), request)
+}
+
+func (c *BrokerClient) Update(instanceID string, request BrokerUpdateRequest, acceptsIncomplete bool) (*BrokerResponse, error) {
+	return c.Request("PATCH", instancePath(instanceID)+acceptsIncompleteQuery(acceptsIncomplete), request)
+}
+
+func (c *BrokerClient) Deprovision(instanceID, serviceID, planID string, acceptsIncomplete bool) (*BrokerResponse, error) {
+	query := url.Values{"service_id": {serviceID}, "plan_id": {planID}}
+	if acceptsIncomplete {
+		query.Set("accepts_incomplete", "true")
+	}
+
+	return c.Request("DELETE", instancePath(instanceID)+"?"+query.Encode(), nil)
+}
+
+func (c *BrokerClient) Bind(instanceID, bindingID string, request BrokerBindRequest) (*BrokerResponse, error) {
+	return c.Request("PUT", bindingPath(instanceID, bindingID), request)
+}
+
+func (c *BrokerClient) Unbind(instanceID, bindingID, serviceID, planID string) (*BrokerResponse, error) {
+	query := url.Values{"service_id": {serviceID}, "plan_id": {planID}}
+	return c.Request("DELETE", bindingPath(instanceID, bindingID)+"?"+query.Encode(), nil)
+}
+
+// LastOperation polls the state of an asynchronous operation on the
+// instance. operation is what the broker answered the operation with, if
+// anything.
+func (c *BrokerClient) LastOperation(instanceID, serviceID, planID, operation string) (*BrokerResponse, error) {
+	query := url.Values{"service_id": {serviceID}, "plan_id": {planID}}
+	if operation != "" {
+		query.Set("operation", operation)
+	}
+
+	return c.Request("GET", instancePath(instanceID)+"/last_operation?"+query.Encode(), nil)
+}
+
+// Request sends body, encoded as JSON unless it is nil, to path on the
+// broker. It only fails if the broker cannot be reached; any status is
+// returned in the response.
+func (c *BrokerClient) Request(method, path string, body interface{}) (*BrokerResponse, error) {
+	var reader io.Reader
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(buf)
+	}
+
+	req, err := http.NewRequest(method, c.url+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if c.Username != "" || c.Password != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	if c.APIVersion != "" {
+		req.Header.Set("X-Broker-API-Version", c.APIVersion)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	buf, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BrokerResponse{StatusCode: resp.StatusCode, Body: buf}, nil
+}
+
+func instancePath(instanceID string) string {
+	return "/v2/service_instances/" + url.PathEscape(instanceID)
+}
+
+func bindingPath(instanceID, bindingID string) string {
+	return instancePath(instanceID) + "/service_bindings/" + url.PathEscape(bindingID)
+}
+
+func acceptsIncompleteQuery(acceptsIncomplete bool) string {
+	if !acceptsIncomplete {
+		return ""
+	}
+
+	return "?accepts_incomplete=true"
+}
@@ -0,0 +1,154 @@
+package helpers_test
+
+import (
+	"net/http"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
+)
+
+var _ = Describe("BrokerClient", func() {
+	var (
+		broker *fakeBroker
+		client *helpers.BrokerClient
+	)
+
+	provisionRequest := helpers.BrokerProvisionRequest{
+		ServiceID:        "service-1",
+		PlanID:           "plan-2",
+		OrganizationGUID: "org-1",
+		SpaceGUID:        "space-1",
+	}
+
+	BeforeEach(func() {
+		broker = newFakeBroker()
+		client = helpers.NewBrokerClient(helpers.MysqlIntegrationConfig{
+			CFConfig:       &config.Config{},
+			BrokerHost:     broker.Listener.Addr().String(),
+			BrokerProtocol: "http",
+			BrokerUsername: fakeBrokerUsername,
+			BrokerPassword: fakeBrokerPassword,
+		})
+	})
+
+	AfterEach(func() {
+		broker.Close()
+	})
+
+	It("decodes the catalog", func() {
+		catalog, err := client.Catalog()
+		Expect(err).NotTo(HaveOccurred())
+
+		service, found := catalog.Service("p-mysql")
+		Expect(found).To(BeTrue())
+		Expect(service.ID).To(Equal("service-1"))
+		Expect(service.PlanUpdateable).To(BeTrue())
+
+		plan, found := service.Plan("10mb")
+		Expect(found).To(BeTrue())
+		Expect(plan.Description).To(Equal("Maximum 10MB storage"))
+		Expect(*plan.Free).To(BeTrue())
+		Expect(plan.Metadata).To(Equal(helpers.BrokerPlanMetadata{
+			DisplayName:        "10 MB",
+			Bullets:            []string{"10 MB storage", "40 concurrent connections"},
+			MaxStorageMb:       10,
+			MaxUserConnections: 40,
+		}))
+
+		_, found = service.Plan("1gb")
+		Expect(found).To(BeFalse())
+
+		Expect(broker.headers[0].Get("X-Broker-API-Version")).To(Equal("2.11"))
+	})
+
+	It("fails to fetch the catalog without credentials", func() {
+		client.Username = ""
+		client.Password = ""
+
+		_, err := client.Catalog()
+		Expect(err).To(MatchError("GET /v2/catalog returned 401: HTTP Basic: Access denied.\n"))
+		Expect(broker.headers[0].Get("Authorization")).To(BeEmpty())
+	})
+
+	It("returns the status the broker answers with", func() {
+		client.APIVersion = ""
+
+		resp, err := client.Request("GET", "/v2/catalog", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusPreconditionFailed))
+		Expect(broker.headers[0]).NotTo(HaveKey("X-Broker-Api-Version"))
+	})
+
+	It("provisions, binds, unbinds and deprovisions", func() {
+		resp, err := client.Provision("instance-1", provisionRequest, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+
+		var provisioned helpers.BrokerProvisionResponse
+		Expect(resp.Decode(&provisioned)).To(Succeed())
+		Expect(provisioned.DashboardURL).To(Equal("https://p-mysql.example.com/manage/instances/instance-1"))
+
+		resp, err = client.Provision("instance-1", provisionRequest, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		conflicting := provisionRequest
+		conflicting.PlanID = "plan-1"
+		resp, err = client.Provision("instance-1", conflicting, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusConflict))
+
+		resp, err = client.Bind("instance-1", "binding-1", helpers.BrokerBindRequest{ServiceID: "service-1", PlanID: "plan-2", AppGUID: "app-1"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+
+		var bound helpers.BrokerBindResponse
+		Expect(resp.Decode(&bound)).To(Succeed())
+		Expect(bound.Credentials).To(HaveKeyWithValue("hostname", "10.244.7.2"))
+
+		resp, err = client.Unbind("instance-1", "binding-1", "service-1", "plan-2")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		resp, err = client.Deprovision("instance-1", "service-1", "plan-2", false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		resp, err = client.Deprovision("instance-1", "service-1", "plan-2", false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusGone))
+	})
+
+	It("decodes the errors of refused updates", func() {
+		resp, err := client.Update("instance-1", helpers.BrokerUpdateRequest{
+			ServiceID:      "service-1",
+			PlanID:         "plan-1",
+			PreviousValues: &helpers.BrokerPreviousValues{PlanID: "plan-2"},
+		}, true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusUnprocessableEntity))
+
+		var refused helpers.BrokerErrorResponse
+		Expect(resp.Decode(&refused)).To(Succeed())
+		Expect(refused).To(Equal(helpers.BrokerErrorResponse{Error: "PlanChangeNotSupported", Description: "Cannot downgrade"}))
+	})
+
+	It("polls the last operation", func() {
+		resp, err := client.LastOperation("instance-1", "service-1", "plan-2", "provision")
+		Expect(err).NotTo(HaveOccurred())
+
+		var lastOperation helpers.BrokerLastOperationResponse
+		Expect(resp.Decode(&lastOperation)).To(Succeed())
+		Expect(lastOperation).To(Equal(helpers.BrokerLastOperationResponse{State: "succeeded", Description: "provision"}))
+	})
+
+	It("fails to decode a body that is not JSON", func() {
+		resp := &helpers.BrokerResponse{StatusCode: http.StatusBadGateway, Body: []byte("Bad Gateway")}
+
+		var lastOperation helpers.BrokerLastOperationResponse
+		Expect(resp.Decode(&lastOperation)).To(MatchError(ContainSubstring(`Decoding the broker response "Bad Gateway"`)))
+	})
+})
@@ -0,0 +1,123 @@
+package helpers
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// PlanDiff is one way a plan in the broker catalog or in Cloud Controller
+// differs from the config.
+type PlanDiff struct {
+	Plan     string
+	Field    string
+	Expected string
+	Actual   string
+}
+
+// DiffCatalog compares the plans of the service in the broker catalog and
+// in Cloud Controller with the configured plans. Plans are matched by
+// name and their limits must equal the config exactly, as must their
+// description and bullets where the config sets them; every difference is
+// returned, in the order of the configured plans followed by the plans
+// that are not configured.
+func DiffCatalog(service Service, brokerService BrokerService, ccPlans []CFServicePlan) []PlanDiff {
+	var diffs []PlanDiff
+	add := func(plan, field, expected, actual string) {
+		if expected != actual {
+			diffs = append(diffs, PlanDiff{Plan: plan, Field: field, Expected: expected, Actual: actual})
+		}
+	}
+
+	// diffPlan compares what one source advertises for the plan
+	diffPlan := func(plan Plan, source, description string, metadata BrokerPlanMetadata) {
+		if plan.Description != "" {
+			add(plan.Name, "description"+source, strconv.Quote(plan.Description), strconv.Quote(description))
+		}
+		if len(plan.Bullets) > 0 {
+			add(plan.Name, "bullets"+source, fmt.Sprintf("%q", plan.Bullets), fmt.Sprintf("%q", metadata.Bullets))
+		}
+		add(plan.Name, "max_storage_mb"+source, strconv.Itoa(plan.MaxStorageMb), strconv.Itoa(metadata.MaxStorageMb))
+		add(plan.Name, "max_user_connections"+source, strconv.Itoa(plan.MaxUserConnections), strconv.Itoa(metadata.MaxUserConnections))
+	}
+
+	ccPlansByName := map[string]CFServicePlan{}
+	for _, ccPlan := range ccPlans {
+		ccPlansByName[ccPlan.Name] = ccPlan
+	}
+
+	for _, plan := range service.Plans {
+		brokerPlan, inCatalog := brokerService.Plan(plan.Name)
+		ccPlan, inCC := ccPlansByName[plan.Name]
+
+		if !inCatalog {
+			add(plan.Name, "broker catalog", "offered", "missing")
+		}
+		if !inCC {
+			add(plan.Name, "Cloud Controller", "offered", "missing")
+		}
+
+		if inCatalog {
+			diffPlan(plan, "", brokerPlan.Description, brokerPlan.Metadata)
+		}
+		if inCC {
+			add(plan.Name, "visibility", expectedVisibility(plan), visibility(ccPlan))
+			diffPlan(plan, " in Cloud Controller", ccPlan.Description, ccPlan.BrokerCatalog.Metadata)
+		}
+	}
+
+	configured := map[string]bool{}
+	for _, plan := range service.Plans {
+		configured[plan.Name] = true
+	}
+
+	for _, brokerPlan := range brokerService.Plans {
+		if !configured[brokerPlan.Name] {
+			add(brokerPlan.Name, "config", "missing", "offered by the broker")
+		}
+	}
+
+	for _, ccPlan := range ccPlans {
+		_, inCatalog := brokerService.Plan(ccPlan.Name)
+		if !configured[ccPlan.Name] && !inCatalog {
+			add(ccPlan.Name, "config", "missing", "offered by Cloud Controller")
+		}
+	}
+
+	return diffs
+}
+
+// FormatPlanDiffs lists the differences plan by plan.
+func FormatPlanDiffs(diffs []PlanDiff) string {
+	var buf bytes.Buffer
+
+	plan := ""
+	for _, diff := range diffs {
+		if diff.Plan != plan {
+			plan = diff.Plan
+			fmt.Fprintf(&buf, "Plan %s:\n", plan)
+		}
+
+		fmt.Fprintf(&buf, "  %s: expected %s, got %s\n", diff.Field, diff.Expected, diff.Actual)
+	}
+
+	return buf.String()
+}
+
+func expectedVisibility(plan Plan) string {
+	if plan.Private {
+		return "private"
+	}
+
+	return "public"
+}
+
+// visibility tells public plans from those only visible to admins or
+// to some orgs.
+func visibility(plan CFServicePlan) string {
+	if plan.VisibilityType == "public" {
+		return "public"
+	}
+
+	return "private"
+}
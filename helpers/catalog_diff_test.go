@@ -0,0 +1,105 @@
+package helpers_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
+)
+
+var _ = Describe("DiffCatalog", func() {
+	var (
+		service       helpers.Service
+		brokerService helpers.BrokerService
+		ccPlans       []helpers.CFServicePlan
+	)
+
+	BeforeEach(func() {
+		service = helpers.Service{Name: "p-mysql", Plans: []helpers.Plan{
+			{Name: "10mb", MaxStorageMb: 10, MaxUserConnections: 40, Description: "Maximum 10MB storage", Bullets: []string{"10 MB storage", "40 concurrent connections"}},
+			{Name: "1gb", MaxStorageMb: 1024, MaxUserConnections: 100, Private: true, Description: "Maximum 1GB storage", Bullets: []string{"1 GB storage", "100 concurrent connections"}},
+		}}
+
+		metadata := []helpers.BrokerPlanMetadata{
+			{Bullets: []string{"10 MB storage", "40 concurrent connections"}, MaxStorageMb: 10, MaxUserConnections: 40},
+			{Bullets: []string{"1 GB storage", "100 concurrent connections"}, MaxStorageMb: 1024, MaxUserConnections: 100},
+		}
+
+		brokerService = helpers.BrokerService{Name: "p-mysql", Plans: []helpers.BrokerPlan{
+			{Name: "10mb", Description: "Maximum 10MB storage", Metadata: metadata[0]},
+			{Name: "1gb", Description: "Maximum 1GB storage", Metadata: metadata[1]},
+		}}
+
+		ccPlans = []helpers.CFServicePlan{
+			{Name: "10mb", Description: "Maximum 10MB storage", VisibilityType: "public"},
+			{Name: "1gb", Description: "Maximum 1GB storage", VisibilityType: "organization"},
+		}
+		ccPlans[0].BrokerCatalog.Metadata = metadata[0]
+		ccPlans[1].BrokerCatalog.Metadata = metadata[1]
+	})
+
+	It("finds no differences when everything matches the config", func() {
+		Expect(helpers.DiffCatalog(service, brokerService, ccPlans)).To(BeEmpty())
+
+		ccPlans[1].VisibilityType = "admin"
+		Expect(helpers.DiffCatalog(service, brokerService, ccPlans)).To(BeEmpty())
+	})
+
+	It("reports the differences plan by plan", func() {
+		service.Plans[0].Private = true
+		service.Plans[1].Bullets = []string{"1 GB storage", "200 concurrent connections"}
+		brokerService.Plans[0].Description = "Maximum 20MB storage"
+		brokerService.Plans[0].Metadata.MaxStorageMb = 20
+		brokerService.Plans = append(brokerService.Plans, helpers.BrokerPlan{Name: "100mb"})
+		ccPlans = append(ccPlans[1:], helpers.CFServicePlan{Name: "legacy", VisibilityType: "public"})
+
+		diffs := helpers.DiffCatalog(service, brokerService, ccPlans)
+		Expect(diffs).To(Equal([]helpers.PlanDiff{
+			{Plan: "10mb", Field: "Cloud Controller", Expected: "offered", Actual: "missing"},
+			{Plan: "10mb", Field: "description", Expected: `"Maximum 10MB storage"`, Actual: `"Maximum 20MB storage"`},
+			{Plan: "10mb", Field: "max_storage_mb", Expected: "10", Actual: "20"},
+			{Plan: "1gb", Field: "bullets", Expected: `["1 GB storage" "200 concurrent connections"]`, Actual: `["1 GB storage" "100 concurrent connections"]`},
+			{Plan: "1gb", Field: "bullets in Cloud Controller", Expected: `["1 GB storage" "200 concurrent connections"]`, Actual: `["1 GB storage" "100 concurrent connections"]`},
+			{Plan: "100mb", Field: "config", Expected: "missing", Actual: "offered by the broker"},
+			{Plan: "legacy", Field: "config", Expected: "missing", Actual: "offered by Cloud Controller"},
+		}))
+
+		Expect(helpers.FormatPlanDiffs(diffs[:3])).To(Equal(`Plan 10mb:
+  Cloud Controller: expected offered, got missing
+  description: expected "Maximum 10MB storage", got "Maximum 20MB storage"
+  max_storage_mb: expected 10, got 20
+`))
+	})
+
+	It("does not check the description and bullets when the config leaves them out", func() {
+		service.Plans[0].Description = ""
+		service.Plans[0].Bullets = nil
+		brokerService.Plans[0].Description = "Maximum 10MB storage, shared"
+		ccPlans[0].BrokerCatalog.Metadata.Bullets = []string{"Shared"}
+
+		Expect(helpers.DiffCatalog(service, brokerService, ccPlans)).To(BeEmpty())
+	})
+
+	It("compares the visibility, description and limits in Cloud Controller", func() {
+		ccPlans[0].VisibilityType = "admin"
+		ccPlans[1].VisibilityType = "public"
+		ccPlans[1].Description = "Maximum 1 GB"
+		ccPlans[1].BrokerCatalog.Metadata.MaxUserConnections = 50
+
+		Expect(helpers.FormatPlanDiffs(helpers.DiffCatalog(service, brokerService, ccPlans))).To(Equal(`Plan 10mb:
+  visibility: expected public, got private
+Plan 1gb:
+  visibility: expected private, got public
+  description in Cloud Controller: expected "Maximum 1GB storage", got "Maximum 1 GB"
+  max_user_connections in Cloud Controller: expected 100, got 50
+`))
+	})
+
+	It("reports a plan missing from the broker catalog", func() {
+		brokerService.Plans = brokerService.Plans[:1]
+
+		Expect(helpers.DiffCatalog(service, brokerService, ccPlans)).To(Equal([]helpers.PlanDiff{
+			{Plan: "1gb", Field: "broker catalog", Expected: "offered", Actual: "missing"},
+		}))
+	})
+})
@@ -0,0 +1,551 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/config"
+)
+
+const (
+	cfAPIRequestTimeout = 60 * time.Second
+	cfOAuthTokenTimeout = 30 * time.Second
+)
+
+// CFAPIClient talks to the CF v3 API, so that specs can assert on the
+// state of their resources rather than on cf CLI output.
+type CFAPIClient struct {
+	client *http.Client
+	api    string
+	token  string
+
+	// JobTimeout bounds how long an asynchronous operation is polled for,
+	// checking every PollInterval.
+	JobTimeout   time.Duration
+	PollInterval time.Duration
+}
+
+// CFAPIError is a CF API response with an unexpected status, with the
+// errors the API gave for it.
+type CFAPIError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Errors     []CFAPIErrorDetail
+}
+
+type CFAPIErrorDetail struct {
+	Code   int    `json:"code"`
+	Title  string `json:"title"`
+	Detail string `json:"detail"`
+}
+
+func (e *CFAPIError) Error() string {
+	var details []string
+	for _, detail := range e.Errors {
+		details = append(details, fmt.Sprintf("%s: %s", detail.Title, detail.Detail))
+	}
+
+	message := fmt.Sprintf("%s %s returned %d", e.Method, e.Path, e.StatusCode)
+	if len(details) > 0 {
+		message += ": " + strings.Join(details, "; ")
+	}
+
+	return message
+}
+
+// CFRelationships are the to-one relationships of a v3 resource, by name.
+type CFRelationships map[string]struct {
+	Data struct {
+		GUID string `json:"guid"`
+	} `json:"data"`
+}
+
+// GUID returns the GUID of the related resource, or "" if there is none.
+func (r CFRelationships) GUID(name string) string {
+	return r[name].Data.GUID
+}
+
+type CFOrg struct {
+	GUID string `json:"guid"`
+	Name string `json:"name"`
+}
+
+type CFSpace struct {
+	GUID          string          `json:"guid"`
+	Name          string          `json:"name"`
+	Relationships CFRelationships `json:"relationships"`
+}
+
+type CFApp struct {
+	GUID          string          `json:"guid"`
+	Name          string          `json:"name"`
+	State         string          `json:"state"`
+	Relationships CFRelationships `json:"relationships"`
+}
+
+type CFDroplet struct {
+	GUID       string `json:"guid"`
+	State      string `json:"state"`
+	Buildpacks []struct {
+		Name string `json:"name"`
+	} `json:"buildpacks"`
+}
+
+// CFServiceOffering is an offering of a broker. BrokerCatalog.ID is the
+// ID of the service in the broker's catalog.
+type CFServiceOffering struct {
+	GUID          string `json:"guid"`
+	Name          string `json:"name"`
+	Available     bool   `json:"available"`
+	BrokerCatalog struct {
+		ID string `json:"id"`
+	} `json:"broker_catalog"`
+}
+
+// CFServicePlan is a plan of an offering. BrokerCatalog holds the plan's
+// metadata as the broker's catalog gave it.
+type CFServicePlan struct {
+	GUID           string `json:"guid"`
+	Name           string `json:"name"`
+	Description    string `json:"description"`
+	VisibilityType string `json:"visibility_type"`
+	Available      bool   `json:"available"`
+	BrokerCatalog  struct {
+		ID       string             `json:"id"`
+		Metadata BrokerPlanMetadata `json:"metadata"`
+	} `json:"broker_catalog"`
+	Relationships CFRelationships `json:"relationships"`
+}
+
+type CFLastOperation struct {
+	Type        string `json:"type"`
+	State       string `json:"state"`
+	Description string `json:"description"`
+}
+
+type CFServiceInstance struct {
+	GUID          string          `json:"guid"`
+	Name          string          `json:"name"`
+	Type          string          `json:"type"`
+	DashboardURL  string          `json:"dashboard_url"`
+	LastOperation CFLastOperation `json:"last_operation"`
+	Relationships CFRelationships `json:"relationships"`
+}
+
+// CFServiceCredentialBinding is a binding of Type "app" or a service key,
+// of Type "key".
+type CFServiceCredentialBinding struct {
+	GUID          string          `json:"guid"`
+	Name          string          `json:"name"`
+	Type          string          `json:"type"`
+	LastOperation CFLastOperation `json:"last_operation"`
+	Relationships CFRelationships `json:"relationships"`
+}
+
+type CFJob struct {
+	GUID      string             `json:"guid"`
+	Operation string             `json:"operation"`
+	State     string             `json:"state"`
+	Errors    []CFAPIErrorDetail `json:"errors"`
+}
+
+func NewCFAPIClient(apiEndpoint, token string, skipSSLValidation bool) *CFAPIClient {
+	return &CFAPIClient{
+		client:       newHTTPClient(skipSSLValidation, cfAPIRequestTimeout),
+		api:          cfAPIURL(apiEndpoint),
+		token:        token,
+		JobTimeout:   5 * time.Minute,
+		PollInterval: 2 * time.Second,
+	}
+}
+
+// NewCFAPIClientForAdmin logs in as the admin user of the config.
+func NewCFAPIClientForAdmin(cfConfig *config.Config) (*CFAPIClient, error) {
+	token, err := cfLogin(newHTTPClient(cfConfig.SkipSSLValidation, cfAPIRequestTimeout), cfConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewCFAPIClient(cfConfig.ApiEndpoint, token, cfConfig.SkipSSLValidation), nil
+}
+
+// NewCFAPIClientForCLIUser acts as the user the cf CLI is logged in as,
+// which in a spec is the test context's user.
+func NewCFAPIClientForCLIUser(cfConfig *config.Config) (*CFAPIClient, error) {
+	session, err := RunCf(cfOAuthTokenTimeout, "oauth-token")
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(session.Out.Contents())), "\n")
+	token := strings.TrimSpace(lines[len(lines)-1])
+	if !strings.HasPrefix(strings.ToLower(token), "bearer ") {
+		return nil, fmt.Errorf("cf oauth-token did not print a bearer token")
+	}
+
+	return NewCFAPIClient(cfConfig.ApiEndpoint, token[len("bearer "):], cfConfig.SkipSSLValidation), nil
+}
+
+func (c *CFAPIClient) FindOrg(name string) (CFOrg, error) {
+	var org CFOrg
+	return org, c.findOne("org", name, "/v3/organizations?names="+url.QueryEscape(name), &org)
+}
+
+func (c *CFAPIClient) FindSpace(orgGUID, name string) (CFSpace, error) {
+	var space CFSpace
+	return space, c.findOne("space", name, "/v3/spaces?names="+url.QueryEscape(name)+"&organization_guids="+orgGUID, &space)
+}
+
+// FindApp finds the app by name in the space, or in every space the user
+// can see if spaceGUID is empty.
+func (c *CFAPIClient) FindApp(spaceGUID, name string) (CFApp, error) {
+	var app CFApp
+	return app, c.findOne("app", name, "/v3/apps?names="+url.QueryEscape(name)+spaceFilter(spaceGUID), &app)
+}
+
+func (c *CFAPIClient) CurrentDroplet(appGUID string) (CFDroplet, error) {
+	var droplet CFDroplet
+	return droplet, c.get("/v3/apps/"+appGUID+"/droplets/current", &droplet)
+}
+
+// ServiceOfferings lists the offerings with the given names that the user
+// can see.
+func (c *CFAPIClient) ServiceOfferings(names ...string) ([]CFServiceOffering, error) {
+	var offerings []CFServiceOffering
+	err := c.list("/v3/service_offerings?names="+joinQuery(names), func(resource json.RawMessage) error {
+		var offering CFServiceOffering
+		err := json.Unmarshal(resource, &offering)
+		offerings = append(offerings, offering)
+		return err
+	})
+
+	return offerings, err
+}
+
+// ServicePlans lists the plans of the offering that the user can see.
+func (c *CFAPIClient) ServicePlans(offeringGUID string) ([]CFServicePlan, error) {
+	var plans []CFServicePlan
+	err := c.list("/v3/service_plans?service_offering_guids="+offeringGUID, func(resource json.RawMessage) error {
+		var plan CFServicePlan
+		err := json.Unmarshal(resource, &plan)
+		plans = append(plans, plan)
+		return err
+	})
+
+	return plans, err
+}
+
+// FindServiceInstance finds the instance by name in the space, or in every
+// space the user can see if spaceGUID is empty.
+func (c *CFAPIClient) FindServiceInstance(spaceGUID, name string) (CFServiceInstance, error) {
+	var instance CFServiceInstance
+	return instance, c.findOne("service instance", name, "/v3/service_instances?names="+url.QueryEscape(name)+spaceFilter(spaceGUID), &instance)
+}
+
+// CreateServiceInstance creates a managed service instance and waits for
+// the broker to provision it.
+func (c *CFAPIClient) CreateServiceInstance(spaceGUID, planGUID, name string, parameters map[string]interface{}) (CFServiceInstance, error) {
+	body := map[string]interface{}{
+		"type": "managed",
+		"name": name,
+		"relationships": map[string]interface{}{
+			"space":        relationship(spaceGUID),
+			"service_plan": relationship(planGUID),
+		},
+	}
+	if parameters != nil {
+		body["parameters"] = parameters
+	}
+
+	if err := c.startJob("POST", "/v3/service_instances", body); err != nil {
+		return CFServiceInstance{}, err
+	}
+
+	return c.FindServiceInstance(spaceGUID, name)
+}
+
+// DeleteServiceInstance deletes the instance and waits for the broker to
+// deprovision it.
+func (c *CFAPIClient) DeleteServiceInstance(guid string) error {
+	return c.startJob("DELETE", "/v3/service_instances/"+guid, nil)
+}
+
+// ServiceCredentialBindings lists the app bindings and keys of the
+// instance.
+func (c *CFAPIClient) ServiceCredentialBindings(serviceInstanceGUID string) ([]CFServiceCredentialBinding, error) {
+	var bindings []CFServiceCredentialBinding
+	err := c.list("/v3/service_credential_bindings?service_instance_guids="+serviceInstanceGUID, func(resource json.RawMessage) error {
+		var binding CFServiceCredentialBinding
+		err := json.Unmarshal(resource, &binding)
+		bindings = append(bindings, binding)
+		return err
+	})
+
+	return bindings, err
+}
+
+// CreateServiceKey creates a key and waits for the broker to bind it.
+func (c *CFAPIClient) CreateServiceKey(serviceInstanceGUID, name string, parameters map[string]interface{}) (CFServiceCredentialBinding, error) {
+	return c.createCredentialBinding(map[string]interface{}{
+		"type": "key",
+		"name": name,
+		"relationships": map[string]interface{}{
+			"service_instance": relationship(serviceInstanceGUID),
+		},
+	}, parameters, func(binding CFServiceCredentialBinding) bool {
+		return binding.Type == "key" && binding.Name == name
+	}, serviceInstanceGUID)
+}
+
+// CreateAppBinding binds the app to the instance and waits for the broker.
+func (c *CFAPIClient) CreateAppBinding(appGUID, serviceInstanceGUID string, parameters map[string]interface{}) (CFServiceCredentialBinding, error) {
+	return c.createCredentialBinding(map[string]interface{}{
+		"type": "app",
+		"relationships": map[string]interface{}{
+			"app":              relationship(appGUID),
+			"service_instance": relationship(serviceInstanceGUID),
+		},
+	}, parameters, func(binding CFServiceCredentialBinding) bool {
+		return binding.Type == "app" && binding.Relationships.GUID("app") == appGUID
+	}, serviceInstanceGUID)
+}
+
+// ServiceCredentialBindingCredentials returns the credentials the broker
+// gave the binding or key.
+func (c *CFAPIClient) ServiceCredentialBindingCredentials(guid string) (map[string]interface{}, error) {
+	var details struct {
+		Credentials map[string]interface{} `json:"credentials"`
+	}
+
+	return details.Credentials, c.get("/v3/service_credential_bindings/"+guid+"/details", &details)
+}
+
+// DeleteServiceCredentialBinding unbinds an app or deletes a key and waits
+// for the broker.
+func (c *CFAPIClient) DeleteServiceCredentialBinding(guid string) error {
+	return c.startJob("DELETE", "/v3/service_credential_bindings/"+guid, nil)
+}
+
+// WaitForJob polls the job at jobURL, as given in the Location of an
+// asynchronous operation, until it completes. A failed job is returned
+// with an error carrying the job's errors.
+func (c *CFAPIClient) WaitForJob(jobURL string) (CFJob, error) {
+	path, err := apiPath(jobURL)
+	if err != nil {
+		return CFJob{}, err
+	}
+	deadline := time.Now().Add(c.JobTimeout)
+
+	for {
+		var job CFJob
+		if err := c.get(path, &job); err != nil {
+			return job, err
+		}
+
+		switch job.State {
+		case "COMPLETE":
+			return job, nil
+		case "FAILED":
+			return job, &CFAPIError{Method: "GET", Path: path, StatusCode: http.StatusOK, Errors: job.Errors}
+		}
+
+		if time.Now().After(deadline) {
+			return job, fmt.Errorf("%s did not complete within %s: it is %s", job.Operation, c.JobTimeout, job.State)
+		}
+
+		time.Sleep(c.PollInterval)
+	}
+}
+
+func (c *CFAPIClient) createCredentialBinding(body, parameters map[string]interface{}, matches func(CFServiceCredentialBinding) bool, serviceInstanceGUID string) (CFServiceCredentialBinding, error) {
+	if parameters != nil {
+		body["parameters"] = parameters
+	}
+
+	if err := c.startJob("POST", "/v3/service_credential_bindings", body); err != nil {
+		return CFServiceCredentialBinding{}, err
+	}
+
+	bindings, err := c.ServiceCredentialBindings(serviceInstanceGUID)
+	if err != nil {
+		return CFServiceCredentialBinding{}, err
+	}
+
+	for _, binding := range bindings {
+		if matches(binding) {
+			return binding, nil
+		}
+	}
+
+	return CFServiceCredentialBinding{}, fmt.Errorf("The created %s binding is not listed", body["type"])
+}
+
+// startJob makes a request that the API may carry out asynchronously, and
+// waits for its job if it does.
+func (c *CFAPIClient) startJob(method, path string, body interface{}) error {
+	resp, err := c.do(method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		return nil
+	case http.StatusAccepted:
+		_, err := c.WaitForJob(resp.Header.Get("Location"))
+		return err
+	default:
+		return apiError(resp)
+	}
+}
+
+func (c *CFAPIClient) findOne(kind, name, path string, into interface{}) error {
+	found := false
+	err := c.list(path, func(resource json.RawMessage) error {
+		if found {
+			return nil
+		}
+
+		found = true
+		return json.Unmarshal(resource, into)
+	})
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return fmt.Errorf("No %s named %s", kind, name)
+	}
+
+	return nil
+}
+
+// list calls each with every resource on every page of the listing.
+func (c *CFAPIClient) list(path string, each func(json.RawMessage) error) error {
+	for next := path; next != ""; {
+		var page struct {
+			Pagination struct {
+				Next *struct {
+					Href string `json:"href"`
+				} `json:"next"`
+			} `json:"pagination"`
+			Resources []json.RawMessage `json:"resources"`
+		}
+
+		if err := c.get(next, &page); err != nil {
+			return err
+		}
+
+		for _, resource := range page.Resources {
+			if err := each(resource); err != nil {
+				return fmt.Errorf("Decoding %s: %s", path, err.Error())
+			}
+		}
+
+		next = ""
+		if page.Pagination.Next != nil {
+			var err error
+			if next, err = apiPath(page.Pagination.Next.Href); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// apiPath is the path and query of a URL the API links to, such as a job's
+// Location or the next page. They are requested on the configured API,
+// whatever scheme and host the API reports for itself.
+func apiPath(href string) (string, error) {
+	parsed, err := url.Parse(href)
+	if err != nil {
+		return "", fmt.Errorf("Parsing the API link %q: %s", href, err.Error())
+	}
+
+	return parsed.RequestURI(), nil
+}
+
+func (c *CFAPIClient) get(path string, into interface{}) error {
+	resp, err := c.do("GET", path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return apiError(resp)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(into); err != nil {
+		return fmt.Errorf("Decoding %s: %s", path, err.Error())
+	}
+
+	return nil
+}
+
+func (c *CFAPIClient) do(method, path string, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(buf)
+	}
+
+	req, err := http.NewRequest(method, c.api+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "bearer "+c.token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return c.client.Do(req)
+}
+
+func apiError(resp *http.Response) error {
+	apiErr := &CFAPIError{Method: resp.Request.Method, Path: resp.Request.URL.RequestURI(), StatusCode: resp.StatusCode}
+
+	var body struct {
+		Errors []CFAPIErrorDetail `json:"errors"`
+	}
+	if buf, err := ioutil.ReadAll(resp.Body); err == nil && json.Unmarshal(buf, &body) == nil {
+		apiErr.Errors = body.Errors
+	}
+
+	return apiErr
+}
+
+func relationship(guid string) map[string]interface{} {
+	return map[string]interface{}{"data": map[string]string{"guid": guid}}
+}
+
+func spaceFilter(spaceGUID string) string {
+	if spaceGUID == "" {
+		return ""
+	}
+
+	return "&space_guids=" + spaceGUID
+}
+
+func joinQuery(values []string) string {
+	escaped := make([]string, len(values))
+	for i, value := range values {
+		escaped[i] = url.QueryEscape(value)
+	}
+
+	return strings.Join(escaped, ",")
+}
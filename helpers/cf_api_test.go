@@ -0,0 +1,314 @@
+package helpers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
+)
+
+var _ = Describe("CFAPIClient", func() {
+	var (
+		server    *httptest.Server
+		responses map[string]func(w http.ResponseWriter, r *http.Request)
+		mu        sync.Mutex
+		requests  []string
+		bodies    map[string]string
+		client    *helpers.CFAPIClient
+	)
+
+	respond := func(status int, body string) func(w http.ResponseWriter, r *http.Request) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			fmt.Fprint(w, body)
+		}
+	}
+
+	// jobRunning answers with the job in the given states, one per poll
+	jobRunning := func(states ...string) func(w http.ResponseWriter, r *http.Request) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			state := states[0]
+			if len(states) > 1 {
+				states = states[1:]
+			}
+
+			errors := "[]"
+			if state == "FAILED" {
+				errors = `[{"code": 10009, "title": "CF-UnableToPerform", "detail": "Service broker error: no capacity"}]`
+			}
+			fmt.Fprintf(w, `{"guid": "job-1", "operation": "service_instance.create", "state": %q, "errors": %s}`, state, errors)
+		}
+	}
+
+	BeforeEach(func() {
+		requests = nil
+		bodies = map[string]string{}
+		responses = map[string]func(w http.ResponseWriter, r *http.Request){}
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			request := r.Method + " " + r.URL.RequestURI()
+			requests = append(requests, request)
+			if body, _ := ioutil.ReadAll(r.Body); len(body) > 0 {
+				bodies[request] = string(body)
+			}
+
+			if r.Header.Get("Authorization") != "bearer user-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			respond, found := responses[request]
+			if !found {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"errors": [{"code": 10000, "title": "CF-NotFound", "detail": "Unknown request"}]}`)
+				return
+			}
+			respond(w, r)
+		}))
+
+		client = helpers.NewCFAPIClient(server.URL, "user-token", false)
+		client.PollInterval = time.Millisecond
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("lists every page of the service plans", func() {
+		responses["GET /v3/service_offerings?names=p-mysql"] = respond(http.StatusOK, `{
+			"pagination": {"next": null},
+			"resources": [{"guid": "offering-1", "name": "p-mysql", "available": true}]
+		}`)
+		responses["GET /v3/service_plans?service_offering_guids=offering-1"] = respond(http.StatusOK, fmt.Sprintf(`{
+			"pagination": {"next": {"href": "%s/v3/service_plans?service_offering_guids=offering-1&page=2"}},
+			"resources": [{"guid": "plan-1", "name": "10mb", "visibility_type": "public", "available": true}]
+		}`, server.URL))
+		responses["GET /v3/service_plans?service_offering_guids=offering-1&page=2"] = respond(http.StatusOK, `{
+			"pagination": {"next": null},
+			"resources": [{
+				"guid": "plan-2", "name": "100mb", "visibility_type": "organization", "available": true,
+				"relationships": {"service_offering": {"data": {"guid": "offering-1"}}}
+			}]
+		}`)
+
+		offerings, err := client.ServiceOfferings("p-mysql")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(offerings).To(Equal([]helpers.CFServiceOffering{{GUID: "offering-1", Name: "p-mysql", Available: true}}))
+
+		plans, err := client.ServicePlans(offerings[0].GUID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(plans).To(HaveLen(2))
+		Expect(plans[0].Name).To(Equal("10mb"))
+		Expect(plans[1].Name).To(Equal("100mb"))
+		Expect(plans[1].VisibilityType).To(Equal("organization"))
+		Expect(plans[1].Relationships.GUID("service_offering")).To(Equal("offering-1"))
+	})
+
+	It("finds an app and its current droplet by name", func() {
+		responses["GET /v3/apps?names=some-app&space_guids=space-1"] = respond(http.StatusOK, `{
+			"pagination": {"next": null},
+			"resources": [{"guid": "app-1", "name": "some-app", "state": "STARTED"}]
+		}`)
+		responses["GET /v3/apps/app-1/droplets/current"] = respond(http.StatusOK, `{
+			"guid": "droplet-1", "state": "STAGED", "buildpacks": [{"name": "ruby_buildpack"}]
+		}`)
+
+		app, err := client.FindApp("space-1", "some-app")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(app.State).To(Equal("STARTED"))
+
+		droplet, err := client.CurrentDroplet(app.GUID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(droplet.State).To(Equal("STAGED"))
+		Expect(droplet.Buildpacks[0].Name).To(Equal("ruby_buildpack"))
+	})
+
+	It("fails to find what is not listed", func() {
+		responses["GET /v3/service_instances?names=some+instance"] = respond(http.StatusOK, `{"pagination": {"next": null}, "resources": []}`)
+
+		_, err := client.FindServiceInstance("", "some instance")
+		Expect(err).To(MatchError("No service instance named some instance"))
+	})
+
+	It("creates a service instance and waits for its job", func() {
+		responses["POST /v3/service_instances"] = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Location", server.URL+"/v3/jobs/job-1")
+			w.WriteHeader(http.StatusAccepted)
+		}
+		responses["GET /v3/jobs/job-1"] = jobRunning("PROCESSING", "POLLING", "COMPLETE")
+		responses["GET /v3/service_instances?names=some-instance&space_guids=space-1"] = respond(http.StatusOK, `{
+			"pagination": {"next": null},
+			"resources": [{
+				"guid": "instance-1", "name": "some-instance", "type": "managed",
+				"dashboard_url": "https://p-mysql.bosh-lite.com/manage/instances/instance-1",
+				"last_operation": {"type": "create", "state": "succeeded"}
+			}]
+		}`)
+
+		instance, err := client.CreateServiceInstance("space-1", "plan-1", "some-instance", map[string]interface{}{"read-only": true})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(instance.GUID).To(Equal("instance-1"))
+		Expect(instance.DashboardURL).To(Equal("https://p-mysql.bosh-lite.com/manage/instances/instance-1"))
+		Expect(instance.LastOperation).To(Equal(helpers.CFLastOperation{Type: "create", State: "succeeded"}))
+
+		Expect(requests).To(Equal([]string{
+			"POST /v3/service_instances",
+			"GET /v3/jobs/job-1",
+			"GET /v3/jobs/job-1",
+			"GET /v3/jobs/job-1",
+			"GET /v3/service_instances?names=some-instance&space_guids=space-1",
+		}))
+
+		var body map[string]interface{}
+		Expect(json.Unmarshal([]byte(bodies["POST /v3/service_instances"]), &body)).To(Succeed())
+		Expect(body).To(Equal(map[string]interface{}{
+			"type": "managed",
+			"name": "some-instance",
+			"relationships": map[string]interface{}{
+				"space":        map[string]interface{}{"data": map[string]interface{}{"guid": "space-1"}},
+				"service_plan": map[string]interface{}{"data": map[string]interface{}{"guid": "plan-1"}},
+			},
+			"parameters": map[string]interface{}{"read-only": true},
+		}))
+	})
+
+	It("returns the errors of a failed job", func() {
+		responses["DELETE /v3/service_instances/instance-1"] = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Location", server.URL+"/v3/jobs/job-1")
+			w.WriteHeader(http.StatusAccepted)
+		}
+		responses["GET /v3/jobs/job-1"] = jobRunning("PROCESSING", "FAILED")
+
+		err := client.DeleteServiceInstance("instance-1")
+		Expect(err).To(BeAssignableToTypeOf(&helpers.CFAPIError{}))
+		Expect(err.(*helpers.CFAPIError).Errors).To(Equal([]helpers.CFAPIErrorDetail{
+			{Code: 10009, Title: "CF-UnableToPerform", Detail: "Service broker error: no capacity"},
+		}))
+	})
+
+	It("polls jobs and pages on the configured API whatever host the API links to", func() {
+		responses["DELETE /v3/service_instances/instance-1"] = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Location", "https://api.internal.example.com/v3/jobs/job-1")
+			w.WriteHeader(http.StatusAccepted)
+		}
+		responses["GET /v3/jobs/job-1"] = jobRunning("COMPLETE")
+
+		responses["GET /v3/service_offerings?names=p-mysql"] = respond(http.StatusOK, `{
+			"pagination": {"next": {"href": "https://api.internal.example.com/v3/service_offerings?names=p-mysql&page=2"}},
+			"resources": []
+		}`)
+		responses["GET /v3/service_offerings?names=p-mysql&page=2"] = respond(http.StatusOK, `{"pagination": {"next": null}, "resources": []}`)
+
+		Expect(client.DeleteServiceInstance("instance-1")).To(Succeed())
+		_, err := client.ServiceOfferings("p-mysql")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(requests).To(Equal([]string{
+			"DELETE /v3/service_instances/instance-1",
+			"GET /v3/jobs/job-1",
+			"GET /v3/service_offerings?names=p-mysql",
+			"GET /v3/service_offerings?names=p-mysql&page=2",
+		}))
+	})
+
+	It("stops waiting for a job that does not complete", func() {
+		responses["GET /v3/jobs/job-1"] = jobRunning("POLLING")
+		client.JobTimeout = 20 * time.Millisecond
+
+		job, err := client.WaitForJob(server.URL + "/v3/jobs/job-1")
+		Expect(err).To(MatchError("service_instance.create did not complete within 20ms: it is POLLING"))
+		Expect(job.State).To(Equal("POLLING"))
+	})
+
+	It("returns the status and errors of a rejected request", func() {
+		responses["POST /v3/service_credential_bindings"] = respond(http.StatusBadGateway, `{
+			"errors": [{"code": 10001, "title": "CF-ServiceBrokerBadResponse", "detail": "The service broker rejected the request: invalid parameters"}]
+		}`)
+
+		_, err := client.CreateServiceKey("instance-1", "some-key", map[string]interface{}{"read_only": true})
+		Expect(err).To(MatchError("POST /v3/service_credential_bindings returned 502: CF-ServiceBrokerBadResponse: The service broker rejected the request: invalid parameters"))
+		Expect(bodies["POST /v3/service_credential_bindings"]).To(MatchJSON(`{
+			"type": "key",
+			"name": "some-key",
+			"relationships": {"service_instance": {"data": {"guid": "instance-1"}}},
+			"parameters": {"read_only": true}
+		}`))
+	})
+
+	It("returns the key it created with its credentials", func() {
+		responses["POST /v3/service_credential_bindings"] = respond(http.StatusCreated, `{"guid": "key-1"}`)
+		responses["GET /v3/service_credential_bindings?service_instance_guids=instance-1"] = respond(http.StatusOK, `{
+			"pagination": {"next": null},
+			"resources": [
+				{"guid": "binding-1", "type": "app", "relationships": {"app": {"data": {"guid": "app-1"}}}},
+				{"guid": "key-1", "name": "some-key", "type": "key"}
+			]
+		}`)
+		responses["GET /v3/service_credential_bindings/key-1/details"] = respond(http.StatusOK, `{
+			"credentials": {"hostname": "10.244.7.2", "port": 3306}
+		}`)
+
+		key, err := client.CreateServiceKey("instance-1", "some-key", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(key.GUID).To(Equal("key-1"))
+
+		credentials, err := client.ServiceCredentialBindingCredentials(key.GUID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(credentials).To(HaveKeyWithValue("hostname", "10.244.7.2"))
+	})
+
+	Describe("acting as the cf CLI user", func() {
+		var originalCf func(args ...string) *gexec.Session
+		var oauthToken string
+
+		BeforeEach(func() {
+			originalCf = cf.Cf
+			cf.Cf = func(args ...string) *gexec.Session {
+				session, err := gexec.Start(exec.Command("sh", "-c", "echo "+oauthToken), GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				return session
+			}
+		})
+
+		AfterEach(func() {
+			cf.Cf = originalCf
+		})
+
+		It("uses the token the cf CLI prints", func() {
+			oauthToken = "'bearer user-token'"
+			responses["GET /v3/organizations?names=some-org"] = respond(http.StatusOK, `{
+				"pagination": {"next": null},
+				"resources": [{"guid": "org-1", "name": "some-org"}]
+			}`)
+
+			client, err := helpers.NewCFAPIClientForCLIUser(&config.Config{ApiEndpoint: server.URL})
+			Expect(err).NotTo(HaveOccurred())
+
+			org, err := client.FindOrg("some-org")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(org).To(Equal(helpers.CFOrg{GUID: "org-1", Name: "some-org"}))
+		})
+
+		It("fails when the cf CLI is not logged in", func() {
+			oauthToken = "FAILED"
+
+			_, err := helpers.NewCFAPIClientForCLIUser(&config.Config{ApiEndpoint: server.URL})
+			Expect(err).To(MatchError("cf oauth-token did not print a bearer token"))
+		})
+	})
+})
@@ -0,0 +1,49 @@
+package helpers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// CfMajorVersion is the major version of the installed cf CLI, detected
+// before suites with a test context run. Zero means unknown, which is
+// treated like the v6 CLI.
+var CfMajorVersion int
+
+const cfVersionTimeout = 30 * time.Second
+
+var cfVersionPattern = regexp.MustCompile(`version (\d+)\.`)
+
+// DetectCfMajorVersion runs `cf version`.
+func DetectCfMajorVersion() (int, error) {
+	session, err := RunCf(cfVersionTimeout, "version")
+	if err != nil {
+		return 0, err
+	}
+
+	match := cfVersionPattern.FindSubmatch(session.Out.Contents())
+	if match == nil {
+		return 0, fmt.Errorf("Cannot tell the cf CLI version from %q", session.Out.Contents())
+	}
+
+	return strconv.Atoi(string(match[1]))
+}
+
+// CfV7 reports whether the cf CLI is v7 or later, where push no longer
+// takes -d and service operations are asynchronous unless they are given
+// --wait.
+func CfV7() bool {
+	return CfMajorVersion >= 7
+}
+
+// WithWait appends --wait to a service operation on the CLIs where it would
+// otherwise return before the broker is done.
+func WithWait(args ...string) []string {
+	if CfV7() {
+		return append(args, "--wait")
+	}
+
+	return args
+}
@@ -0,0 +1,52 @@
+package helpers_test
+
+import (
+	"os/exec"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
+)
+
+var _ = Describe("DetectCfMajorVersion", func() {
+	var (
+		originalCf func(args ...string) *gexec.Session
+		cfOutput   string
+	)
+
+	BeforeEach(func() {
+		originalCf = cf.Cf
+		cf.Cf = func(args ...string) *gexec.Session {
+			Expect(args).To(Equal([]string{"version"}))
+
+			session, err := gexec.Start(exec.Command("echo", cfOutput), GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			return session
+		}
+	})
+
+	AfterEach(func() {
+		cf.Cf = originalCf
+	})
+
+	It("reads the major version from cf version", func() {
+		for output, version := range map[string]int{
+			"cf version 6.53.0+8e2b70a4a.2020-10-01": 6,
+			"cf version 7.5.0+0ad1d6398.2022-06-04":  7,
+			"cf8 version 8.7.1+9c81242.2023-06-15":   8,
+		} {
+			cfOutput = output
+			Expect(helpers.DetectCfMajorVersion()).To(Equal(version), output)
+		}
+	})
+
+	It("fails on output it does not recognise", func() {
+		cfOutput = "command not found"
+
+		_, err := helpers.DetectCfMajorVersion()
+		Expect(err).To(MatchError(`Cannot tell the cf CLI version from "command not found\n"`))
+	})
+})
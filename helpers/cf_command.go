@@ -0,0 +1,58 @@
+package helpers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	"github.com/onsi/gomega/gexec"
+)
+
+// CfCommandError is a cf command that exited with a non-zero status, or
+// that did not exit within its timeout and was killed.
+type CfCommandError struct {
+	Args     []string
+	ExitCode int
+	TimedOut bool
+	Timeout  time.Duration
+	Output   string
+}
+
+func (e *CfCommandError) Error() string {
+	if e.TimedOut {
+		return fmt.Sprintf("cf %s did not exit within %s", e.Args[0], e.Timeout)
+	}
+
+	// the cf CLI prints the reason for failing last
+	lines := strings.Split(strings.TrimSpace(e.Output), "\n")
+	return fmt.Sprintf("cf %s exited with status %d: %s", e.Args[0], e.ExitCode, lines[len(lines)-1])
+}
+
+// RunCf runs cf through cf.Cf and waits for it to exit, killing it after
+// timeout. Unlike Session.Wait it does not fail the spec: it returns a
+// *CfCommandError when cf fails or has to be killed.
+func RunCf(timeout time.Duration, args ...string) (*gexec.Session, error) {
+	session := cf.Cf(args...)
+
+	timedOut := false
+	select {
+	case <-session.Exited:
+	case <-time.After(timeout):
+		session.Kill()
+		<-session.Exited
+		timedOut = true
+	}
+
+	if timedOut || session.ExitCode() != 0 {
+		return session, &CfCommandError{
+			Args:     args,
+			ExitCode: session.ExitCode(),
+			TimedOut: timedOut,
+			Timeout:  timeout,
+			Output:   string(session.Out.Contents()) + string(session.Err.Contents()),
+		}
+	}
+
+	return session, nil
+}
@@ -1,18 +1,15 @@
 package helpers
 
 import (
-	"bytes"
-	"crypto/tls"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 )
 
 type CipherFinderClient struct {
-	client *http.Client
-	host   string
+	appClient
+	host string
 }
 
 type Pinger interface {
@@ -20,21 +17,22 @@ type Pinger interface {
 }
 
 func NewCipherFinderClient(host string, skipSSLValidation bool) CipherFinderClient {
-	c := CipherFinderClient{
-		host: host,
-	}
+	return NewCipherFinderClientWithOptions(host, AppClientOptions{SkipSSLValidation: skipSSLValidation})
+}
 
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: skipSSLValidation},
+func NewCipherFinderClientWithOptions(host string, options AppClientOptions) CipherFinderClient {
+	return CipherFinderClient{
+		appClient: newAppClient(options),
+		host:      host,
 	}
-
-	c.client = &http.Client{Transport: tr}
-
-	return c
 }
 
 func (c CipherFinderClient) Ciphers() (string, error) {
-	resp, err := c.do("GET", fmt.Sprintf("%s/ciphers", c.host), "")
+	return c.CiphersContext(context.Background())
+}
+
+func (c CipherFinderClient) CiphersContext(ctx context.Context) (string, error) {
+	resp, err := c.do(ctx, "GET", fmt.Sprintf("%s/ciphers", c.host), "")
 	if err != nil {
 		return "", err
 	}
@@ -49,7 +47,11 @@ func (c CipherFinderClient) Ciphers() (string, error) {
 }
 
 func (c CipherFinderClient) Ping() error {
-	ret, err := c.do("GET", fmt.Sprintf("%s/ping", c.host), "")
+	return c.PingContext(context.Background())
+}
+
+func (c CipherFinderClient) PingContext(ctx context.Context) error {
+	ret, err := c.do(ctx, "GET", fmt.Sprintf("%s/ping", c.host), "")
 	if err != nil {
 		return err
 	}
@@ -60,27 +62,3 @@ func (c CipherFinderClient) Ping() error {
 
 	return nil
 }
-
-func (c CipherFinderClient) do(method, uri, body string) (string, error) {
-	req, err := http.NewRequest(method, uri, bytes.NewBuffer([]byte(body)))
-	if err != nil {
-		return "", err
-	}
-
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return "", err
-	}
-
-	buf, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("%s - %s", resp.Status, string(buf))
-	}
-
-	return string(buf), nil
-
-}
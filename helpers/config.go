@@ -1,7 +1,6 @@
 package helpers
 
 import (
-	"fmt"
 	"os"
 
 	"encoding/json"
@@ -15,11 +14,21 @@ type Component struct {
 	SshTunnel string `json:"ssh_tunnel"`
 }
 
+// Plan is a plan of a service as the broker should advertise it, down to
+// the description and bullets of the catalog.
 type Plan struct {
-	Name               string `json:"name"`
-	MaxStorageMb       int    `json:"max_storage_mb"`
-	MaxUserConnections int    `json:"max_user_connections"`
-	Private            bool   `json:"private,omitempty"`
+	Name               string   `json:"name"`
+	MaxStorageMb       int      `json:"max_storage_mb"`
+	MaxUserConnections int      `json:"max_user_connections"`
+	Private            bool     `json:"private,omitempty"`
+	Description        string   `json:"description,omitempty"`
+	Bullets            []string `json:"bullets,omitempty"`
+}
+
+// Service is a service offering of the broker, with the plans to test.
+type Service struct {
+	Name  string `json:"name"`
+	Plans []Plan `json:"plans"`
 }
 
 type Proxy struct {
@@ -41,20 +50,33 @@ type Tuning struct {
 	ExpectationFilePath string `json:"expectation_file_path"`
 }
 
+// DefaultMaxQuotaTestMb is the largest plan the quota specs fill up when
+// max_quota_test_mb is not set.
+const DefaultMaxQuotaTestMb = 100
+
+// NamePrefix is prepended to the name of every org, space, app and service
+// instance the suites create.
+const NamePrefix = "MySQLATS"
+
 type MysqlIntegrationConfig struct {
-	CFConfig       *config.Config
-	BOSH           BOSH        `json:"bosh"`
-	BrokerHost     string      `json:"broker_host,omitempty"`
-	BrokerProtocol string      `json:"broker_protocol,omitempty"`
-	ServiceName    string      `json:"service_name"`
-	EnableTlsTests bool        `json:"enable_tls_tests"`
-	Plans          []Plan      `json:"plans"`
-	Brokers        []Component `json:"brokers,omitempty"`
-	MysqlNodes     []Component `json:"mysql_nodes,omitempty"`
-	Proxy          Proxy       `json:"proxy"`
-	Standalone     Standalone  `json:"standalone,omitempty"`
-	StandaloneOnly bool        `json:"standalone_only,omitempty"`
-	Tuning         Tuning      `json:"tuning,omitempty"`
+	CFConfig       *config.Config `json:"-"`
+	BOSH           BOSH           `json:"bosh"`
+	BrokerHost     string         `json:"broker_host,omitempty"`
+	BrokerProtocol string         `json:"broker_protocol,omitempty"`
+	BrokerUsername string         `json:"broker_username,omitempty"`
+	BrokerPassword string         `json:"broker_password,omitempty"`
+	ServiceName    string         `json:"service_name,omitempty"`
+	EnableTlsTests bool           `json:"enable_tls_tests"`
+	Plans          []Plan         `json:"plans,omitempty"`
+	Services       []Service      `json:"services,omitempty"`
+	Brokers        []Component    `json:"brokers,omitempty"`
+	MysqlNodes     []Component    `json:"mysql_nodes,omitempty"`
+	Arbitrators    []Component    `json:"arbitrators,omitempty"`
+	Proxy          Proxy          `json:"proxy"`
+	Standalone     Standalone     `json:"standalone,omitempty"`
+	StandaloneOnly bool           `json:"standalone_only,omitempty"`
+	Tuning         Tuning         `json:"tuning,omitempty"`
+	MaxQuotaTestMb int            `json:"max_quota_test_mb,omitempty"`
 }
 
 type BOSH struct {
@@ -62,116 +84,138 @@ type BOSH struct {
 	Client       string `json:"client"`
 	ClientSecret string `json:"client_secret"`
 	URL          string `json:"url"`
+	UAAURL       string `json:"uaa_url,omitempty"`
+}
+
+// AllServices returns the services under test. 'service_name' and 'plans'
+// are a shorthand for a config with a single service.
+func (c MysqlIntegrationConfig) AllServices() []Service {
+	if len(c.Services) > 0 {
+		return c.Services
+	}
+
+	if c.ServiceName == "" && len(c.Plans) == 0 {
+		return nil
+	}
+
+	return []Service{{Name: c.ServiceName, Plans: c.Plans}}
 }
 
 func (c MysqlIntegrationConfig) AppURI(appname string) string {
 	return "https://" + appname + "." + c.CFConfig.AppsDomain
 }
 
-func LoadConfig() (MysqlIntegrationConfig, error) {
-	mysqlIntegrationConfig := MysqlIntegrationConfig{}
+// MarshalJSON writes the cf-test-helpers settings at the top level, next to
+// our own fields, so that the output can be read back by LoadConfig.
+func (c MysqlIntegrationConfig) MarshalJSON() ([]byte, error) {
+	type mysqlIntegrationConfig MysqlIntegrationConfig
 
-	path := os.Getenv("CONFIG")
-	if path == "" {
-		return mysqlIntegrationConfig, fmt.Errorf("Must set $CONFIG to point to an integration config .json file.")
-	}
-
-	buf, err := ioutil.ReadFile(path)
+	mysqlFields, err := json.Marshal(mysqlIntegrationConfig(c))
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	if err := json.Unmarshal(buf, &mysqlIntegrationConfig); err != nil {
-		panic(err)
+	if c.CFConfig == nil {
+		return mysqlFields, nil
 	}
 
-	cfConfig := &config.Config{
-		NamePrefix: "MySQLATS",
-	}
-	if !mysqlIntegrationConfig.StandaloneOnly {
-		err = config.Load(path, cfConfig)
-		if err != nil {
-			return mysqlIntegrationConfig, fmt.Errorf("Loading config: %s", err.Error())
-		}
-		mysqlIntegrationConfig.CFConfig = cfConfig
+	cfFields, err := json.Marshal(c.CFConfig)
+	if err != nil {
+		return nil, err
 	}
 
+	merged := map[string]json.RawMessage{}
+	if err := json.Unmarshal(cfFields, &merged); err != nil {
+		return nil, err
+	}
 
-	if mysqlIntegrationConfig.BrokerProtocol == "" {
-		mysqlIntegrationConfig.BrokerProtocol = "https"
+	if err := json.Unmarshal(mysqlFields, &merged); err != nil {
+		return nil, err
 	}
 
-	return mysqlIntegrationConfig, nil
+	return json.Marshal(merged)
 }
 
-func ValidateConfig(config *MysqlIntegrationConfig) error {
-	if config.StandaloneOnly {
-		if config.Standalone.Host == "" {
-			return fmt.Errorf("Field 'standalone.host' must not be empty")
-		}
-
-		if config.Standalone.Port == 0 {
-			return fmt.Errorf("Field 'standalone.port' must not be empty")
-		}
+func NewCFConfig() *config.Config {
+	return &config.Config{
+		NamePrefix: NamePrefix,
+	}
+}
 
-		if config.Standalone.MySQLUsername == "" {
-			return fmt.Errorf("Field 'standalone.username' must not be empty")
-		}
+func LoadConfig() (MysqlIntegrationConfig, error) {
+	mysqlIntegrationConfig := MysqlIntegrationConfig{}
 
-		if config.Standalone.MySQLPassword == "" {
-			return fmt.Errorf("Field 'standalone.password' must not be empty")
-		}
+	path := os.Getenv("CONFIG")
+	if path == "" {
+		return mysqlIntegrationConfig, ConfigNotFoundError{}
+	}
 
-		return nil
+	document, err := readConfigDocument(path)
+	if err != nil {
+		return mysqlIntegrationConfig, err
 	}
 
-	if config.ServiceName == "" {
-		return fmt.Errorf("Field 'service_name' must not be empty")
+	document, err = selectProfile(document, path, os.Getenv("CONFIG_PROFILE"))
+	if err != nil {
+		return mysqlIntegrationConfig, err
 	}
 
-	if config.Plans == nil {
-		return fmt.Errorf("Field 'plans' must not be nil")
+	err = applyEnvOverrides(document, os.LookupEnv)
+	if err != nil {
+		return mysqlIntegrationConfig, err
 	}
 
-	if len(config.Plans) == 0 {
-		return fmt.Errorf("Field 'plans' must not be empty")
+	err = resolveSecrets(document, os.LookupEnv)
+	if err != nil {
+		return mysqlIntegrationConfig, err
 	}
 
-	for index, plan := range config.Plans {
-		if plan.Name == "" {
-			return fmt.Errorf("Field 'plans[%d].name' must not be empty", index)
-		}
+	buf, err := json.Marshal(document)
+	if err != nil {
+		return mysqlIntegrationConfig, err
+	}
 
-		if plan.MaxStorageMb == 0 {
-			return fmt.Errorf("Field 'plans[%d].max_storage_mb' must not be empty", index)
-		}
+	if err := json.Unmarshal(buf, &mysqlIntegrationConfig); err != nil {
+		return mysqlIntegrationConfig, fieldTypeError(err)
+	}
 
-		if plan.MaxUserConnections == 0 {
-			return fmt.Errorf("Field 'plans[%d].max_user_connections' must not be empty", index)
+	if !mysqlIntegrationConfig.StandaloneOnly {
+		cfConfig, err := loadCFConfig(buf)
+		if err != nil {
+			return mysqlIntegrationConfig, err
 		}
+		mysqlIntegrationConfig.CFConfig = cfConfig
 	}
 
-	if config.BrokerHost == "" {
-		return fmt.Errorf("Field 'broker_host' must not be empty")
+	if mysqlIntegrationConfig.BrokerProtocol == "" {
+		mysqlIntegrationConfig.BrokerProtocol = "https"
 	}
 
-	if len(config.Proxy.DashboardUrls) == 0 {
-		return fmt.Errorf("Field 'proxy.dashboardUrls' must not be empty")
+	if mysqlIntegrationConfig.MaxQuotaTestMb == 0 {
+		mysqlIntegrationConfig.MaxQuotaTestMb = DefaultMaxQuotaTestMb
 	}
 
-	for index, url := range config.Proxy.DashboardUrls {
-		if url == "" {
-			return fmt.Errorf("Field 'proxy.dashboard_urls[%d]' must not be empty", index)
-		}
+	return mysqlIntegrationConfig, nil
+}
+
+// loadCFConfig hands the effective config document to cf-test-helpers, which
+// only reads configs from disk.
+func loadCFConfig(document []byte) (*config.Config, error) {
+	f, err := ioutil.TempFile("", "mysql-ats-cf-config")
+	if err != nil {
+		return nil, err
 	}
+	defer os.Remove(f.Name())
+	defer f.Close()
 
-	if config.Proxy.APIUsername == "" {
-		return fmt.Errorf("Field 'proxy.api_username' must not be empty")
+	if _, err := f.Write(document); err != nil {
+		return nil, err
 	}
 
-	if config.Proxy.APIPassword == "" {
-		return fmt.Errorf("Field 'proxy.api_password' must not be empty")
+	cfConfig := NewCFConfig()
+	if err := config.Load(f.Name(), cfConfig); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return cfConfig, nil
 }
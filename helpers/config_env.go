@@ -0,0 +1,221 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/config"
+)
+
+// EnvPrefix is prepended to the upper-cased JSON path of a config field to
+// form the name of the environment variable that overrides it, e.g.
+// MYSQL_ATS_PROXY_API_PASSWORD overrides proxy.api_password.
+const EnvPrefix = "MYSQL_ATS_"
+
+const redactedValue = "[REDACTED]"
+
+// secretFields are the JSON paths of config fields holding credentials.
+var secretFields = [][]string{
+	{"admin_password"},
+	{"existing_user_password"},
+	{"test_password"},
+	{"docker_password"},
+	{"broker_password"},
+	{"bosh", "client_secret"},
+	{"proxy", "api_password"},
+	{"standalone", "password"},
+}
+
+type envOverride struct {
+	name      string
+	path      []string
+	fieldType reflect.Type
+}
+
+// EnvVarNames returns the name of every environment variable that can
+// override a field of the integration config, sorted alphabetically.
+func EnvVarNames() []string {
+	var names []string
+	for _, override := range envOverrides() {
+		names = append(names, override.name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func envOverrides() []envOverride {
+	overrides := collectEnvOverrides(reflect.TypeOf(config.Config{}), nil)
+	return append(overrides, collectEnvOverrides(reflect.TypeOf(MysqlIntegrationConfig{}), nil)...)
+}
+
+func collectEnvOverrides(t reflect.Type, parent []string) []envOverride {
+	var overrides []envOverride
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		path := append(append([]string{}, parent...), name)
+
+		if field.Type.Kind() == reflect.Struct {
+			overrides = append(overrides, collectEnvOverrides(field.Type, path)...)
+			continue
+		}
+
+		overrides = append(overrides, envOverride{
+			name:      EnvPrefix + strings.ToUpper(strings.Join(path, "_")),
+			path:      path,
+			fieldType: field.Type,
+		})
+	}
+
+	return overrides
+}
+
+// applyEnvOverrides replaces the values in the decoded config document with
+// those of any MYSQL_ATS_* variable returned by lookupEnv. Every variable
+// whose value does not fit its field is reported in a ValidationError.
+func applyEnvOverrides(document map[string]interface{}, lookupEnv func(string) (string, bool)) error {
+	var errs []FieldError
+
+	for _, override := range envOverrides() {
+		value, found := lookupEnv(override.name)
+		if !found {
+			continue
+		}
+
+		parsed, err := parseEnvValue(value, override.fieldType)
+		if err != nil {
+			errs = append(errs, FieldError{
+				Field:  strings.Join(override.path, "."),
+				Reason: fmt.Sprintf("is set by $%s to an invalid value: %s", override.name, err.Error()),
+			})
+			continue
+		}
+
+		setPath(document, override.path, parsed)
+	}
+
+	if len(errs) > 0 {
+		return ValidationError{Errors: errs}
+	}
+
+	return nil
+}
+
+func parseEnvValue(value string, fieldType reflect.Type) (interface{}, error) {
+	switch fieldType.Kind() {
+	case reflect.String:
+		return value, nil
+	case reflect.Bool:
+		return strconv.ParseBool(value)
+	case reflect.Int, reflect.Int64:
+		return strconv.Atoi(value)
+	case reflect.Float64:
+		return strconv.ParseFloat(value, 64)
+	case reflect.Slice:
+		if strings.HasPrefix(strings.TrimSpace(value), "[") {
+			var parsed []interface{}
+			if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+				return nil, err
+			}
+
+			return parsed, nil
+		}
+
+		if fieldType.Elem().Kind() == reflect.String {
+			var parsed []interface{}
+			for _, item := range strings.Split(value, ",") {
+				parsed = append(parsed, strings.TrimSpace(item))
+			}
+
+			return parsed, nil
+		}
+
+		return nil, fmt.Errorf("must be a JSON array")
+	}
+
+	return nil, fmt.Errorf("unsupported field type %s", fieldType)
+}
+
+func setPath(document map[string]interface{}, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		child, ok := document[key].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			document[key] = child
+		}
+
+		document = child
+	}
+
+	document[path[len(path)-1]] = value
+}
+
+// RedactedJSON returns the effective config as indented JSON, with every
+// credential replaced by a placeholder.
+func (c MysqlIntegrationConfig) RedactedJSON() ([]byte, error) {
+	buf, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var document map[string]interface{}
+	if err := json.Unmarshal(buf, &document); err != nil {
+		return nil, err
+	}
+
+	for _, path := range secretFields {
+		redact(document, path)
+	}
+
+	return json.MarshalIndent(document, "", "  ")
+}
+
+func redact(document map[string]interface{}, path []string) {
+	for _, key := range path[:len(path)-1] {
+		child, ok := document[key].(map[string]interface{})
+		if !ok {
+			return
+		}
+
+		document = child
+	}
+
+	key := path[len(path)-1]
+	if value, ok := document[key].(string); ok && value != "" {
+		document[key] = redactedValue
+	}
+}
+
+// secretValues returns every credential set in the config, so that they can
+// be kept out of anything else that is logged.
+func (c MysqlIntegrationConfig) secretValues() ([]string, error) {
+	buf, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var document map[string]interface{}
+	if err := json.Unmarshal(buf, &document); err != nil {
+		return nil, err
+	}
+
+	var secrets []string
+	for _, path := range secretFields {
+		if _, _, value, ok := secretValue(document, path); ok && value != "" {
+			secrets = append(secrets, value)
+		}
+	}
+
+	return secrets, nil
+}
@@ -0,0 +1,163 @@
+package helpers_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
+)
+
+var _ = Describe("Environment variable overrides", func() {
+	var (
+		tmpDir    string
+		setEnvs   []string
+		oldConfig string
+	)
+
+	setEnv := func(name, value string) {
+		setEnvs = append(setEnvs, name)
+		os.Setenv(name, value)
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "config-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		configPath := filepath.Join(tmpDir, "integration_config.json")
+		err = ioutil.WriteFile(configPath, []byte(`{
+			"api": "https://api.bosh-lite.com",
+			"apps_domain": "bosh-lite.com",
+			"admin_user": "admin",
+			"admin_password": "admin",
+			"broker_host": "p-mysql.bosh-lite.com",
+			"service_name": "p-mysql",
+			"plans": [{"name": "10mb", "max_storage_mb": 10, "max_user_connections": 20}],
+			"proxy": {
+				"dashboard_urls": ["https://proxy-0-p-mysql.bosh-lite.com"],
+				"api_username": "proxy",
+				"api_password": "proxy-secret"
+			},
+			"standalone": {
+				"host": "10.244.7.2",
+				"port": 3306,
+				"username": "root",
+				"password": "root-secret"
+			}
+		}`), 0644)
+		Expect(err).NotTo(HaveOccurred())
+
+		oldConfig = os.Getenv("CONFIG")
+		os.Setenv("CONFIG", configPath)
+	})
+
+	AfterEach(func() {
+		for _, name := range setEnvs {
+			os.Unsetenv(name)
+		}
+		setEnvs = nil
+
+		os.Setenv("CONFIG", oldConfig)
+		os.RemoveAll(tmpDir)
+	})
+
+	It("names a variable after the JSON path of every field", func() {
+		Expect(helpers.EnvVarNames()).To(ContainElement("MYSQL_ATS_API"))
+		Expect(helpers.EnvVarNames()).To(ContainElement("MYSQL_ATS_BROKER_HOST"))
+		Expect(helpers.EnvVarNames()).To(ContainElement("MYSQL_ATS_PROXY_API_PASSWORD"))
+		Expect(helpers.EnvVarNames()).To(ContainElement("MYSQL_ATS_STANDALONE_PORT"))
+		Expect(helpers.EnvVarNames()).To(ContainElement("MYSQL_ATS_BOSH_CLIENT_SECRET"))
+		Expect(helpers.EnvVarNames()).To(ContainElement("MYSQL_ATS_TUNING_EXPECTATION_FILE_PATH"))
+	})
+
+	It("uses the values from the file when no variables are set", func() {
+		cfg, err := helpers.LoadConfig()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.Proxy.APIPassword).To(Equal("proxy-secret"))
+		Expect(cfg.Standalone.Port).To(Equal(3306))
+		Expect(cfg.CFConfig.AdminPassword).To(Equal("admin"))
+	})
+
+	It("prefers the environment over the file", func() {
+		setEnv("MYSQL_ATS_PROXY_API_PASSWORD", "env-proxy-secret")
+		setEnv("MYSQL_ATS_STANDALONE_PORT", "13306")
+		setEnv("MYSQL_ATS_ADMIN_PASSWORD", "env-admin")
+		setEnv("MYSQL_ATS_SKIP_SSL_VALIDATION", "true")
+		setEnv("MYSQL_ATS_PROXY_DASHBOARD_URLS", "https://proxy-0.example.com, https://proxy-1.example.com")
+		setEnv("MYSQL_ATS_PLANS", `[{"name": "100mb", "max_storage_mb": 100, "max_user_connections": 40}]`)
+
+		cfg, err := helpers.LoadConfig()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.Proxy.APIPassword).To(Equal("env-proxy-secret"))
+		Expect(cfg.Proxy.APIUsername).To(Equal("proxy"))
+		Expect(cfg.Standalone.Port).To(Equal(13306))
+		Expect(cfg.CFConfig.AdminPassword).To(Equal("env-admin"))
+		Expect(cfg.CFConfig.SkipSSLValidation).To(BeTrue())
+		Expect(cfg.Proxy.DashboardUrls).To(Equal([]string{"https://proxy-0.example.com", "https://proxy-1.example.com"}))
+		Expect(cfg.Plans).To(Equal([]helpers.Plan{{Name: "100mb", MaxStorageMb: 100, MaxUserConnections: 40}}))
+	})
+
+	It("prefers the file over the defaults, and the environment over both", func() {
+		cfg, err := helpers.LoadConfig()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.BrokerProtocol).To(Equal("https"))
+		Expect(cfg.CFConfig.NamePrefix).To(Equal("MySQLATS"))
+		Expect(cfg.MaxQuotaTestMb).To(Equal(helpers.DefaultMaxQuotaTestMb))
+
+		setEnv("MYSQL_ATS_BROKER_PROTOCOL", "http")
+		setEnv("MYSQL_ATS_NAME_PREFIX", "CIMySQLATS")
+		setEnv("MYSQL_ATS_MAX_QUOTA_TEST_MB", "1024")
+
+		cfg, err = helpers.LoadConfig()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.BrokerProtocol).To(Equal("http"))
+		Expect(cfg.CFConfig.NamePrefix).To(Equal("CIMySQLATS"))
+		Expect(cfg.MaxQuotaTestMb).To(Equal(1024))
+	})
+
+	It("sets fields that are absent from the file", func() {
+		setEnv("MYSQL_ATS_BOSH_URL", "192.168.50.6")
+
+		cfg, err := helpers.LoadConfig()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.BOSH.URL).To(Equal("192.168.50.6"))
+	})
+
+	It("returns an error naming the variable when its value does not match the field type", func() {
+		setEnv("MYSQL_ATS_STANDALONE_PORT", "not-a-port")
+		setEnv("MYSQL_ATS_SKIP_SSL_VALIDATION", "maybe")
+
+		_, err := helpers.LoadConfig()
+		Expect(err).To(BeAssignableToTypeOf(helpers.ValidationError{}))
+		Expect(err.(helpers.ValidationError).Errors).To(ConsistOf(
+			helpers.FieldError{Field: "standalone.port", Reason: `is set by $MYSQL_ATS_STANDALONE_PORT to an invalid value: strconv.Atoi: parsing "not-a-port": invalid syntax`},
+			helpers.FieldError{Field: "skip_ssl_validation", Reason: `is set by $MYSQL_ATS_SKIP_SSL_VALIDATION to an invalid value: strconv.ParseBool: parsing "maybe": invalid syntax`},
+		))
+	})
+
+	Describe("RedactedJSON", func() {
+		It("dumps the effective config without any credentials", func() {
+			setEnv("MYSQL_ATS_BOSH_CLIENT_SECRET", "bosh-secret")
+
+			cfg, err := helpers.LoadConfig()
+			Expect(err).NotTo(HaveOccurred())
+
+			dump, err := cfg.RedactedJSON()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(dump).To(ContainSubstring(`"api_username": "proxy"`))
+			Expect(dump).To(ContainSubstring(`"broker_host": "p-mysql.bosh-lite.com"`))
+			Expect(dump).To(ContainSubstring(`"api_password": "[REDACTED]"`))
+
+			for _, secret := range []string{"proxy-secret", "root-secret", "bosh-secret", `"admin_password": "admin"`} {
+				Expect(string(dump)).NotTo(ContainSubstring(secret))
+			}
+		})
+	})
+})
@@ -0,0 +1,105 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// ConfigErrorExitCode is the exit status of a suite that could not start
+// because of its config, as opposed to one whose specs failed. It matches
+// EX_CONFIG from sysexits.h.
+const ConfigErrorExitCode = 78
+
+var yamlLineRegex = regexp.MustCompile(`^yaml: line (\d+): `)
+
+// ConfigNotFoundError is returned when $CONFIG is unset or names a file that
+// cannot be read.
+type ConfigNotFoundError struct {
+	Path string
+	Err  error
+}
+
+func (e ConfigNotFoundError) Error() string {
+	if e.Path == "" {
+		return "Must set $CONFIG to point to an integration config .json or .yml file."
+	}
+
+	return fmt.Sprintf("Could not read config file '%s': %s", e.Path, e.Err.Error())
+}
+
+// ConfigParseError is returned when the config file is not valid JSON or
+// YAML. Line and Column are 1-based; a zero Column means the parser did not
+// report one.
+type ConfigParseError struct {
+	Path   string
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e ConfigParseError) Error() string {
+	switch {
+	case e.Line == 0:
+		return fmt.Sprintf("%s: %s", e.Path, e.Err.Error())
+	case e.Column == 0:
+		return fmt.Sprintf("%s:%d: %s", e.Path, e.Line, e.Err.Error())
+	default:
+		return fmt.Sprintf("%s:%d:%d: %s", e.Path, e.Line, e.Column, e.Err.Error())
+	}
+}
+
+func newJSONParseError(path string, buf []byte, err error) ConfigParseError {
+	parseError := ConfigParseError{Path: path, Err: err}
+
+	var offset int64
+	switch typed := err.(type) {
+	case *json.SyntaxError:
+		offset = typed.Offset
+	case *json.UnmarshalTypeError:
+		offset = typed.Offset
+	default:
+		return parseError
+	}
+
+	if offset > int64(len(buf)) {
+		offset = int64(len(buf))
+	}
+
+	consumed := buf[:offset]
+	parseError.Line = bytes.Count(consumed, []byte("\n")) + 1
+	parseError.Column = len(consumed) - bytes.LastIndexByte(consumed, '\n') - 1
+	if parseError.Column == 0 {
+		parseError.Column = 1
+	}
+
+	return parseError
+}
+
+func newYAMLParseError(path string, err error) ConfigParseError {
+	parseError := ConfigParseError{Path: path, Err: err}
+
+	// yaml.v2 only reports the line, as part of the message
+	if match := yamlLineRegex.FindStringSubmatch(err.Error()); match != nil {
+		parseError.Line, _ = strconv.Atoi(match[1])
+		parseError.Err = fmt.Errorf("%s", err.Error()[len(match[0]):])
+	}
+
+	return parseError
+}
+
+// fieldTypeError reports a value of the wrong type for a config field, e.g.
+// a string given for 'standalone.port'.
+func fieldTypeError(err error) error {
+	typeError, ok := err.(*json.UnmarshalTypeError)
+	if !ok || typeError.Field == "" {
+		return err
+	}
+
+	return ValidationError{Errors: []FieldError{{
+		Field:  typeError.Field,
+		Reason: fmt.Sprintf("must be of type %s, got %s", typeError.Type, typeError.Value),
+	}}}
+}
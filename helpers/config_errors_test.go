@@ -0,0 +1,112 @@
+package helpers_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
+)
+
+var _ = Describe("Config errors", func() {
+	var (
+		tmpDir    string
+		oldConfig string
+	)
+
+	writeConfig := func(name, contents string) string {
+		configPath := filepath.Join(tmpDir, name)
+		Expect(ioutil.WriteFile(configPath, []byte(contents), 0644)).To(Succeed())
+		os.Setenv("CONFIG", configPath)
+
+		return configPath
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "config-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		oldConfig = os.Getenv("CONFIG")
+	})
+
+	AfterEach(func() {
+		os.Setenv("CONFIG", oldConfig)
+		os.RemoveAll(tmpDir)
+	})
+
+	It("returns a ConfigNotFoundError when $CONFIG is not set", func() {
+		os.Unsetenv("CONFIG")
+
+		_, err := helpers.LoadConfig()
+		Expect(err).To(Equal(helpers.ConfigNotFoundError{}))
+		Expect(err).To(MatchError(ContainSubstring("Must set $CONFIG")))
+	})
+
+	It("returns a ConfigNotFoundError when the file does not exist", func() {
+		configPath := filepath.Join(tmpDir, "missing.json")
+		os.Setenv("CONFIG", configPath)
+
+		_, err := helpers.LoadConfig()
+		Expect(err).To(BeAssignableToTypeOf(helpers.ConfigNotFoundError{}))
+		Expect(err.(helpers.ConfigNotFoundError).Path).To(Equal(configPath))
+	})
+
+	It("reports the line and column of invalid JSON", func() {
+		configPath := writeConfig("integration_config.json", `{
+  "api": "api.bosh-lite.com",
+  "admin_user": admin
+}`)
+
+		_, err := helpers.LoadConfig()
+		Expect(err).To(BeAssignableToTypeOf(helpers.ConfigParseError{}))
+
+		parseError := err.(helpers.ConfigParseError)
+		Expect(parseError.Path).To(Equal(configPath))
+		Expect(parseError.Line).To(Equal(3))
+		Expect(parseError.Column).To(Equal(17))
+		Expect(err.Error()).To(HavePrefix(configPath + ":3:17: "))
+	})
+
+	It("rejects a config that is not an object", func() {
+		configPath := writeConfig("integration_config.json", "null")
+		os.Setenv("MYSQL_ATS_BROKER_HOST", "p-mysql.bosh-lite.com")
+		defer os.Unsetenv("MYSQL_ATS_BROKER_HOST")
+
+		_, err := helpers.LoadConfig()
+		Expect(err).To(Equal(helpers.ConfigParseError{Path: configPath, Err: errors.New("the config must be an object")}))
+	})
+
+	It("reports the line of invalid YAML", func() {
+		configPath := writeConfig("integration_config.yml", `---
+api: api.bosh-lite.com
+admin_user: admin
+admin_password: "admin
+`)
+
+		_, err := helpers.LoadConfig()
+		Expect(err).To(BeAssignableToTypeOf(helpers.ConfigParseError{}))
+
+		parseError := err.(helpers.ConfigParseError)
+		Expect(parseError.Line).To(Equal(4))
+		Expect(parseError.Column).To(BeZero())
+		Expect(err.Error()).To(HavePrefix(configPath + ":4: "))
+	})
+
+	It("returns a ValidationError naming a field of the wrong type", func() {
+		writeConfig("integration_config.json", `{
+  "api": "api.bosh-lite.com",
+  "admin_user": "admin",
+  "admin_password": "admin",
+  "standalone": {"port": "3306"}
+}`)
+
+		_, err := helpers.LoadConfig()
+		Expect(err).To(BeAssignableToTypeOf(helpers.ValidationError{}))
+		Expect(err).To(MatchError("Field 'standalone.port' must be of type int, got string"))
+	})
+})
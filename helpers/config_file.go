@@ -0,0 +1,122 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// readConfigDocument decodes a .json or .yml config file.
+func readConfigDocument(path string) (map[string]interface{}, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, ConfigNotFoundError{Path: path, Err: err}
+	}
+
+	var document map[string]interface{}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		var yamlDocument map[interface{}]interface{}
+		if err := yaml.Unmarshal(buf, &yamlDocument); err != nil {
+			return nil, newYAMLParseError(path, err)
+		}
+
+		document = stringKeys(yamlDocument).(map[string]interface{})
+	default:
+		if err := json.Unmarshal(buf, &document); err != nil {
+			return nil, newJSONParseError(path, buf, err)
+		}
+	}
+
+	// a literal null decodes into a nil map
+	if document == nil {
+		return nil, ConfigParseError{Path: path, Err: errors.New("the config must be an object")}
+	}
+
+	return document, nil
+}
+
+// selectProfile picks one environment out of a config file holding several.
+// Such files keep shared settings under 'defaults' and one entry per
+// environment under 'profiles'; the selected profile is merged on top of the
+// defaults. Files without profiles are returned as they are.
+func selectProfile(document map[string]interface{}, path, profile string) (map[string]interface{}, error) {
+	profiles, hasProfiles := document["profiles"].(map[string]interface{})
+	if !hasProfiles {
+		if profile != "" {
+			return nil, fmt.Errorf("$CONFIG_PROFILE is set to '%s' but %s does not define any profiles", profile, path)
+		}
+
+		return document, nil
+	}
+
+	var names []string
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if profile == "" {
+		return nil, fmt.Errorf("%s defines several profiles, set $CONFIG_PROFILE to one of: %s", path, strings.Join(names, ", "))
+	}
+
+	selected, found := profiles[profile].(map[string]interface{})
+	if !found {
+		return nil, fmt.Errorf("Profile '%s' not found in %s, expected one of: %s", profile, path, strings.Join(names, ", "))
+	}
+
+	merged := map[string]interface{}{}
+	if defaults, ok := document["defaults"].(map[string]interface{}); ok {
+		mergeDocuments(merged, defaults)
+	}
+	mergeDocuments(merged, selected)
+
+	return merged, nil
+}
+
+func mergeDocuments(dst, src map[string]interface{}) {
+	for key, value := range src {
+		srcMap, srcIsMap := value.(map[string]interface{})
+		dstMap, dstIsMap := dst[key].(map[string]interface{})
+
+		if srcIsMap && dstIsMap {
+			mergeDocuments(dstMap, srcMap)
+			continue
+		}
+
+		if srcIsMap {
+			copied := map[string]interface{}{}
+			mergeDocuments(copied, srcMap)
+			value = copied
+		}
+
+		dst[key] = value
+	}
+}
+
+// stringKeys converts the maps produced by the YAML decoder into maps that
+// can be encoded as JSON.
+func stringKeys(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[interface{}]interface{}:
+		converted := map[string]interface{}{}
+		for k, v := range typed {
+			converted[fmt.Sprintf("%v", k)] = stringKeys(v)
+		}
+
+		return converted
+	case []interface{}:
+		for i, v := range typed {
+			typed[i] = stringKeys(v)
+		}
+	}
+
+	return value
+}
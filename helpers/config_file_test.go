@@ -0,0 +1,152 @@
+package helpers_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
+)
+
+var _ = Describe("Loading config files", func() {
+	var (
+		tmpDir     string
+		oldConfig  string
+		oldProfile string
+	)
+
+	writeConfig := func(name, contents string) {
+		configPath := filepath.Join(tmpDir, name)
+		Expect(ioutil.WriteFile(configPath, []byte(contents), 0644)).To(Succeed())
+		os.Setenv("CONFIG", configPath)
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "config-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		oldConfig = os.Getenv("CONFIG")
+		oldProfile = os.Getenv("CONFIG_PROFILE")
+		os.Unsetenv("CONFIG_PROFILE")
+	})
+
+	AfterEach(func() {
+		os.Setenv("CONFIG", oldConfig)
+		os.Setenv("CONFIG_PROFILE", oldProfile)
+		os.RemoveAll(tmpDir)
+	})
+
+	It("reads YAML configs", func() {
+		writeConfig("integration_config.yml", `---
+api: https://api.bosh-lite.com
+apps_domain: bosh-lite.com
+admin_user: admin
+admin_password: admin
+timeout_scale: 2
+service_name: p-mysql
+plans:
+- name: 10mb
+  max_storage_mb: 10
+  max_user_connections: 20
+proxy:
+  dashboard_urls:
+  - https://proxy-0-p-mysql.bosh-lite.com
+  api_username: proxy
+  api_password: proxy-secret
+standalone:
+  port: 3306
+`)
+
+		cfg, err := helpers.LoadConfig()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.ServiceName).To(Equal("p-mysql"))
+		Expect(cfg.Plans).To(Equal([]helpers.Plan{{Name: "10mb", MaxStorageMb: 10, MaxUserConnections: 20}}))
+		Expect(cfg.Proxy.DashboardUrls).To(Equal([]string{"https://proxy-0-p-mysql.bosh-lite.com"}))
+		Expect(cfg.Standalone.Port).To(Equal(3306))
+		Expect(cfg.CFConfig.ApiEndpoint).To(Equal("https://api.bosh-lite.com"))
+		Expect(cfg.CFConfig.TimeoutScale).To(Equal(2.0))
+	})
+
+	Describe("profiles", func() {
+		BeforeEach(func() {
+			writeConfig("environments.yml", `---
+defaults:
+  admin_user: admin
+  service_name: p-mysql
+  plans:
+  - name: 10mb
+    max_storage_mb: 10
+    max_user_connections: 20
+  proxy:
+    api_username: proxy
+    skip_ssl_validation: true
+
+profiles:
+  bosh-lite:
+    api: https://api.bosh-lite.com
+    apps_domain: bosh-lite.com
+    admin_password: admin
+    proxy:
+      api_password: bosh-lite-secret
+
+  staging:
+    api: https://api.sys.staging.example.com
+    apps_domain: apps.staging.example.com
+    admin_password: staging-admin
+    plans:
+    - name: 1gb
+      max_storage_mb: 1024
+      max_user_connections: 40
+    proxy:
+      api_password: staging-secret
+      skip_ssl_validation: false
+`)
+		})
+
+		It("merges the selected profile over the shared defaults", func() {
+			os.Setenv("CONFIG_PROFILE", "staging")
+
+			cfg, err := helpers.LoadConfig()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(cfg.ServiceName).To(Equal("p-mysql"))
+			Expect(cfg.Plans).To(Equal([]helpers.Plan{{Name: "1gb", MaxStorageMb: 1024, MaxUserConnections: 40}}))
+			Expect(cfg.Proxy.APIUsername).To(Equal("proxy"))
+			Expect(cfg.Proxy.APIPassword).To(Equal("staging-secret"))
+			Expect(cfg.Proxy.SkipSSLValidation).To(BeFalse())
+
+			Expect(cfg.CFConfig.ApiEndpoint).To(Equal("https://api.sys.staging.example.com"))
+			Expect(cfg.CFConfig.AdminUser).To(Equal("admin"))
+			Expect(cfg.CFConfig.AdminPassword).To(Equal("staging-admin"))
+		})
+
+		It("inherits every default the profile does not set", func() {
+			os.Setenv("CONFIG_PROFILE", "bosh-lite")
+
+			cfg, err := helpers.LoadConfig()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(cfg.Plans).To(Equal([]helpers.Plan{{Name: "10mb", MaxStorageMb: 10, MaxUserConnections: 20}}))
+			Expect(cfg.Proxy.SkipSSLValidation).To(BeTrue())
+			Expect(cfg.Proxy.APIPassword).To(Equal("bosh-lite-secret"))
+			Expect(cfg.CFConfig.AppsDomain).To(Equal("bosh-lite.com"))
+		})
+
+		It("requires a profile to be selected", func() {
+			_, err := helpers.LoadConfig()
+			Expect(err).To(MatchError(ContainSubstring("set $CONFIG_PROFILE to one of: bosh-lite, staging")))
+		})
+
+		It("returns an error when the selected profile does not exist", func() {
+			os.Setenv("CONFIG_PROFILE", "prod-az1")
+
+			_, err := helpers.LoadConfig()
+			Expect(err).To(MatchError(ContainSubstring("Profile 'prod-az1' not found")))
+		})
+	})
+})
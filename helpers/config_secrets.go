@@ -0,0 +1,90 @@
+package helpers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+const (
+	secretFilePrefix = "file:"
+	secretEnvPrefix  = "env:"
+)
+
+// resolveSecrets replaces credentials given as 'file:<path>' or
+// 'env:<VARIABLE>' with the contents of the file, without its trailing
+// newline, or the value of the environment variable. Every reference that
+// cannot be resolved is reported.
+func resolveSecrets(document map[string]interface{}, lookupEnv func(string) (string, bool)) error {
+	var errs []FieldError
+
+	for _, path := range secretFields {
+		parent, key, value, ok := secretValue(document, path)
+		if !ok {
+			continue
+		}
+
+		field := strings.Join(path, ".")
+
+		switch {
+		case strings.HasPrefix(value, secretFilePrefix):
+			secretPath := strings.TrimPrefix(value, secretFilePrefix)
+
+			contents, err := ioutil.ReadFile(secretPath)
+			if err != nil {
+				errs = append(errs, FieldError{Field: field, Reason: fmt.Sprintf("refers to a file that cannot be read: %s", err.Error())})
+				continue
+			}
+
+			parent[key] = strings.TrimRight(string(contents), "\r\n")
+		case strings.HasPrefix(value, secretEnvPrefix):
+			name := strings.TrimPrefix(value, secretEnvPrefix)
+
+			secret, found := lookupEnv(name)
+			if !found {
+				errs = append(errs, FieldError{Field: field, Reason: fmt.Sprintf("refers to $%s, which is not set", name)})
+				continue
+			}
+
+			parent[key] = secret
+		}
+	}
+
+	if len(errs) > 0 {
+		return ValidationError{Errors: errs}
+	}
+
+	return nil
+}
+
+func secretValue(document map[string]interface{}, path []string) (map[string]interface{}, string, string, bool) {
+	for _, key := range path[:len(path)-1] {
+		child, ok := document[key].(map[string]interface{})
+		if !ok {
+			return nil, "", "", false
+		}
+
+		document = child
+	}
+
+	key := path[len(path)-1]
+	value, ok := document[key].(string)
+
+	return document, key, value, ok
+}
+
+// String keeps credentials out of specs and logs that print the config
+// with %v or %+v.
+func (c MysqlIntegrationConfig) String() string {
+	redacted, err := c.RedactedJSON()
+	if err != nil {
+		return fmt.Sprintf("<config: %s>", err.Error())
+	}
+
+	return string(redacted)
+}
+
+// GoString does the same for %#v.
+func (c MysqlIntegrationConfig) GoString() string {
+	return c.String()
+}
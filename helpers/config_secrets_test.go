@@ -0,0 +1,126 @@
+package helpers_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
+)
+
+var _ = Describe("Secret references", func() {
+	var (
+		tmpDir    string
+		oldConfig string
+		setEnvs   []string
+	)
+
+	setEnv := func(name, value string) {
+		os.Setenv(name, value)
+		setEnvs = append(setEnvs, name)
+	}
+
+	writeFile := func(name, contents string) string {
+		path := filepath.Join(tmpDir, name)
+		Expect(ioutil.WriteFile(path, []byte(contents), 0600)).To(Succeed())
+		return path
+	}
+
+	writeConfig := func(contents string) {
+		os.Setenv("CONFIG", writeFile("integration_config.json", contents))
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "config-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		oldConfig = os.Getenv("CONFIG")
+		setEnvs = nil
+	})
+
+	AfterEach(func() {
+		for _, name := range setEnvs {
+			os.Unsetenv(name)
+		}
+
+		os.Setenv("CONFIG", oldConfig)
+		os.RemoveAll(tmpDir)
+	})
+
+	It("reads secrets from files and environment variables", func() {
+		adminPasswordPath := writeFile("admin-password", "admin-secret\n")
+		proxyPasswordPath := writeFile("proxy-password", "proxy-secret")
+		setEnv("TEST_BOSH_CLIENT_SECRET", "bosh-secret")
+		setEnv("TEST_MYSQL_PASSWORD", "root-secret")
+
+		writeConfig(fmt.Sprintf(`{
+  "api": "api.bosh-lite.com",
+  "admin_user": "admin",
+  "admin_password": "file:%s",
+  "proxy": {"api_password": "file:%s"},
+  "bosh": {"client_secret": "env:TEST_BOSH_CLIENT_SECRET"},
+  "standalone": {"password": "env:TEST_MYSQL_PASSWORD"}
+}`, adminPasswordPath, proxyPasswordPath))
+
+		cfg, err := helpers.LoadConfig()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.CFConfig.AdminPassword).To(Equal("admin-secret"))
+		Expect(cfg.Proxy.APIPassword).To(Equal("proxy-secret"))
+		Expect(cfg.BOSH.ClientSecret).To(Equal("bosh-secret"))
+		Expect(cfg.Standalone.MySQLPassword).To(Equal("root-secret"))
+	})
+
+	It("resolves references given through MYSQL_ATS_* overrides", func() {
+		setEnv("TEST_PROXY_PASSWORD", "proxy-secret")
+		setEnv("MYSQL_ATS_PROXY_API_PASSWORD", "env:TEST_PROXY_PASSWORD")
+
+		writeConfig(`{"api": "api.bosh-lite.com", "admin_user": "admin", "admin_password": "admin"}`)
+
+		cfg, err := helpers.LoadConfig()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.Proxy.APIPassword).To(Equal("proxy-secret"))
+	})
+
+	It("leaves other fields that look like references alone", func() {
+		writeConfig(`{"api": "api.bosh-lite.com", "admin_user": "admin", "admin_password": "admin", "proxy": {"api_username": "env:USER"}}`)
+
+		cfg, err := helpers.LoadConfig()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.Proxy.APIUsername).To(Equal("env:USER"))
+	})
+
+	It("reports every reference that cannot be resolved", func() {
+		writeConfig(fmt.Sprintf(`{
+  "api": "api.bosh-lite.com",
+  "admin_user": "admin",
+  "admin_password": "file:%s",
+  "bosh": {"client_secret": "env:TEST_UNSET_SECRET"}
+}`, filepath.Join(tmpDir, "missing")))
+
+		_, err := helpers.LoadConfig()
+		Expect(err).To(BeAssignableToTypeOf(helpers.ValidationError{}))
+		Expect(err.(helpers.ValidationError).Errors).To(HaveLen(2))
+		Expect(err).To(MatchError(ContainSubstring("Field 'admin_password' refers to a file that cannot be read")))
+		Expect(err).To(MatchError(ContainSubstring("Field 'bosh.client_secret' refers to $TEST_UNSET_SECRET, which is not set")))
+	})
+
+	It("keeps resolved secrets out of the printed config", func() {
+		setEnv("TEST_PROXY_PASSWORD", "proxy-secret")
+		writeConfig(`{"api": "api.bosh-lite.com", "admin_user": "admin", "admin_password": "admin-secret", "proxy": {"api_password": "env:TEST_PROXY_PASSWORD"}}`)
+
+		cfg, err := helpers.LoadConfig()
+		Expect(err).NotTo(HaveOccurred())
+
+		for _, printed := range []string{fmt.Sprint(cfg), fmt.Sprintf("%+v", cfg), fmt.Sprintf("%#v", cfg)} {
+			Expect(printed).To(ContainSubstring(`"api_password": "[REDACTED]"`))
+			Expect(printed).NotTo(ContainSubstring("proxy-secret"))
+			Expect(printed).NotTo(ContainSubstring("admin-secret"))
+		}
+	})
+})
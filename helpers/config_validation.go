@@ -0,0 +1,190 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// FieldError describes a single problem with a config field, identified by
+// its JSON path.
+type FieldError struct {
+	Field  string
+	Reason string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("Field '%s' %s", e.Field, e.Reason)
+}
+
+// ValidationError holds every problem found while validating a config.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, fieldError := range e.Errors {
+		messages[i] = fieldError.Error()
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// ConfigRequirement checks the part of the config a suite depends on.
+type ConfigRequirement func(config *MysqlIntegrationConfig) []FieldError
+
+// ValidateConfig checks the settings shared by the service suites, or only
+// the standalone settings when 'standalone_only' is set.
+func ValidateConfig(config *MysqlIntegrationConfig) error {
+	if config.StandaloneOnly {
+		return ValidateConfigFor(config, RequireStandalone)
+	}
+
+	return ValidateConfigFor(config, RequireService, RequireBroker, RequireProxy)
+}
+
+// ValidateConfigFor runs every requirement and returns a ValidationError
+// listing all of the problems found, or nil.
+func ValidateConfigFor(config *MysqlIntegrationConfig, requirements ...ConfigRequirement) error {
+	var errs []FieldError
+	for _, requirement := range requirements {
+		errs = append(errs, requirement(config)...)
+	}
+
+	if len(errs) > 0 {
+		return ValidationError{Errors: errs}
+	}
+
+	return nil
+}
+
+func notEmpty(field, value string) []FieldError {
+	if value == "" {
+		return []FieldError{{Field: field, Reason: "must not be empty"}}
+	}
+
+	return nil
+}
+
+func RequireCF(config *MysqlIntegrationConfig) []FieldError {
+	if config.CFConfig == nil {
+		return []FieldError{{Field: "standalone_only", Reason: "must not be set for suites that use Cloud Foundry"}}
+	}
+
+	var errs []FieldError
+	errs = append(errs, notEmpty("api", config.CFConfig.ApiEndpoint)...)
+	errs = append(errs, notEmpty("apps_domain", config.CFConfig.AppsDomain)...)
+	errs = append(errs, notEmpty("admin_user", config.CFConfig.AdminUser)...)
+	errs = append(errs, notEmpty("admin_password", config.CFConfig.AdminPassword)...)
+
+	return errs
+}
+
+func RequireService(config *MysqlIntegrationConfig) []FieldError {
+	if len(config.Services) == 0 {
+		return serviceErrors("service_name", "plans", config.ServiceName, config.Plans)
+	}
+
+	var errs []FieldError
+	if config.ServiceName != "" || len(config.Plans) > 0 {
+		errs = append(errs, FieldError{Field: "service_name", Reason: "must not be set together with 'services'"})
+	}
+
+	for index, service := range config.Services {
+		prefix := fmt.Sprintf("services[%d].", index)
+		errs = append(errs, serviceErrors(prefix+"name", prefix+"plans", service.Name, service.Plans)...)
+	}
+
+	return errs
+}
+
+func serviceErrors(nameField, plansField, name string, plans []Plan) []FieldError {
+	var errs []FieldError
+	errs = append(errs, notEmpty(nameField, name)...)
+
+	if len(plans) == 0 {
+		errs = append(errs, FieldError{Field: plansField, Reason: "must not be empty"})
+	}
+
+	for index, plan := range plans {
+		errs = append(errs, notEmpty(fmt.Sprintf("%s[%d].name", plansField, index), plan.Name)...)
+
+		if plan.MaxStorageMb == 0 {
+			errs = append(errs, FieldError{Field: fmt.Sprintf("%s[%d].max_storage_mb", plansField, index), Reason: "must not be empty"})
+		}
+
+		if plan.MaxUserConnections == 0 {
+			errs = append(errs, FieldError{Field: fmt.Sprintf("%s[%d].max_user_connections", plansField, index), Reason: "must not be empty"})
+		}
+	}
+
+	return errs
+}
+
+func RequireBroker(config *MysqlIntegrationConfig) []FieldError {
+	return notEmpty("broker_host", config.BrokerHost)
+}
+
+// RequireBrokerCredentials is for suites that talk to the broker directly.
+func RequireBrokerCredentials(config *MysqlIntegrationConfig) []FieldError {
+	var errs []FieldError
+	errs = append(errs, notEmpty("broker_host", config.BrokerHost)...)
+	errs = append(errs, notEmpty("broker_username", config.BrokerUsername)...)
+	errs = append(errs, notEmpty("broker_password", config.BrokerPassword)...)
+
+	return errs
+}
+
+func RequireProxy(config *MysqlIntegrationConfig) []FieldError {
+	var errs []FieldError
+
+	if len(config.Proxy.DashboardUrls) == 0 {
+		errs = append(errs, FieldError{Field: "proxy.dashboard_urls", Reason: "must not be empty"})
+	}
+
+	for index, url := range config.Proxy.DashboardUrls {
+		errs = append(errs, notEmpty(fmt.Sprintf("proxy.dashboard_urls[%d]", index), url)...)
+	}
+
+	errs = append(errs, notEmpty("proxy.api_username", config.Proxy.APIUsername)...)
+	errs = append(errs, notEmpty("proxy.api_password", config.Proxy.APIPassword)...)
+
+	return errs
+}
+
+func RequireBOSH(config *MysqlIntegrationConfig) []FieldError {
+	var errs []FieldError
+	errs = append(errs, notEmpty("bosh.url", config.BOSH.URL)...)
+	errs = append(errs, notEmpty("bosh.client", config.BOSH.Client)...)
+	errs = append(errs, notEmpty("bosh.client_secret", config.BOSH.ClientSecret)...)
+
+	return errs
+}
+
+func RequireStandalone(config *MysqlIntegrationConfig) []FieldError {
+	var errs []FieldError
+	errs = append(errs, notEmpty("standalone.host", config.Standalone.Host)...)
+
+	if config.Standalone.Port == 0 {
+		errs = append(errs, FieldError{Field: "standalone.port", Reason: "must not be empty"})
+	}
+
+	errs = append(errs, notEmpty("standalone.username", config.Standalone.MySQLUsername)...)
+	errs = append(errs, notEmpty("standalone.password", config.Standalone.MySQLPassword)...)
+
+	return errs
+}
+
+func RequireTuning(config *MysqlIntegrationConfig) []FieldError {
+	path := config.Tuning.ExpectationFilePath
+	if path == "" {
+		return notEmpty("tuning.expectation_file_path", path)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return []FieldError{{Field: "tuning.expectation_file_path", Reason: fmt.Sprintf("must point to a readable file: %s", err.Error())}}
+	}
+
+	return nil
+}
@@ -0,0 +1,181 @@
+package helpers_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/config"
+)
+
+var _ = Describe("Validating the config", func() {
+	var cfg helpers.MysqlIntegrationConfig
+
+	BeforeEach(func() {
+		cfg = helpers.MysqlIntegrationConfig{
+			CFConfig: &config.Config{
+				ApiEndpoint:   "https://api.bosh-lite.com",
+				AppsDomain:    "bosh-lite.com",
+				AdminUser:     "admin",
+				AdminPassword: "admin",
+			},
+			ServiceName: "p-mysql",
+			BrokerHost:  "p-mysql.bosh-lite.com",
+			Plans: []helpers.Plan{
+				{Name: "10mb", MaxStorageMb: 10, MaxUserConnections: 20},
+			},
+			Proxy: helpers.Proxy{
+				DashboardUrls: []string{"https://proxy-0-p-mysql.bosh-lite.com"},
+				APIUsername:   "proxy",
+				APIPassword:   "proxy-secret",
+			},
+		}
+	})
+
+	fieldsOf := func(err error) []string {
+		Expect(err).To(BeAssignableToTypeOf(helpers.ValidationError{}))
+
+		var fields []string
+		for _, fieldError := range err.(helpers.ValidationError).Errors {
+			fields = append(fields, fieldError.Field)
+		}
+
+		return fields
+	}
+
+	It("accepts a complete config", func() {
+		Expect(helpers.ValidateConfig(&cfg)).To(Succeed())
+	})
+
+	It("reports every problem at once", func() {
+		cfg.ServiceName = ""
+		cfg.BrokerHost = ""
+		cfg.Plans = append(cfg.Plans, helpers.Plan{Name: "", MaxStorageMb: 100})
+		cfg.Proxy.DashboardUrls = []string{"https://proxy-0-p-mysql.bosh-lite.com", ""}
+		cfg.Proxy.APIPassword = ""
+
+		err := helpers.ValidateConfig(&cfg)
+		Expect(fieldsOf(err)).To(Equal([]string{
+			"service_name",
+			"plans[1].name",
+			"plans[1].max_user_connections",
+			"broker_host",
+			"proxy.dashboard_urls[1]",
+			"proxy.api_password",
+		}))
+		Expect(err.Error()).To(ContainSubstring("Field 'plans[1].name' must not be empty\nField 'plans[1].max_user_connections' must not be empty"))
+	})
+
+	It("only checks the standalone settings for standalone-only configs", func() {
+		cfg = helpers.MysqlIntegrationConfig{StandaloneOnly: true}
+
+		Expect(fieldsOf(helpers.ValidateConfig(&cfg))).To(Equal([]string{
+			"standalone.host",
+			"standalone.port",
+			"standalone.username",
+			"standalone.password",
+		}))
+	})
+
+	Describe("several services", func() {
+		BeforeEach(func() {
+			cfg.ServiceName = ""
+			cfg.Plans = nil
+			cfg.Services = []helpers.Service{
+				{Name: "p-mysql", Plans: []helpers.Plan{{Name: "10mb", MaxStorageMb: 10, MaxUserConnections: 20}}},
+				{Name: "p-mysql-ha", Plans: []helpers.Plan{{Name: "ha-10mb", MaxStorageMb: 10, MaxUserConnections: 20}}},
+			}
+		})
+
+		It("accepts a complete config", func() {
+			Expect(helpers.ValidateConfig(&cfg)).To(Succeed())
+		})
+
+		It("reports problems by service", func() {
+			cfg.Services[1].Name = ""
+			cfg.Services[1].Plans[0].MaxStorageMb = 0
+
+			Expect(fieldsOf(helpers.ValidateConfig(&cfg))).To(Equal([]string{
+				"services[1].name",
+				"services[1].plans[0].max_storage_mb",
+			}))
+		})
+
+		It("does not allow the single service shorthand as well", func() {
+			cfg.ServiceName = "p-mysql"
+
+			Expect(fieldsOf(helpers.ValidateConfig(&cfg))).To(Equal([]string{"service_name"}))
+		})
+	})
+
+	Describe("AllServices", func() {
+		It("treats 'service_name' and 'plans' as a single service", func() {
+			Expect(cfg.AllServices()).To(Equal([]helpers.Service{
+				{Name: "p-mysql", Plans: cfg.Plans},
+			}))
+		})
+
+		It("returns the configured services", func() {
+			services := []helpers.Service{{Name: "p-mysql"}, {Name: "p-mysql-ha"}}
+			cfg = helpers.MysqlIntegrationConfig{Services: services}
+
+			Expect(cfg.AllServices()).To(Equal(services))
+		})
+
+		It("returns nothing when no service is configured", func() {
+			cfg = helpers.MysqlIntegrationConfig{}
+
+			Expect(cfg.AllServices()).To(BeEmpty())
+		})
+	})
+
+	Describe("suite requirements", func() {
+		It("only checks what the suite asks for", func() {
+			cfg.BrokerHost = ""
+
+			Expect(helpers.ValidateConfigFor(&cfg, helpers.RequireCF, helpers.RequireService)).To(Succeed())
+		})
+
+		It("requires the CF settings", func() {
+			cfg.CFConfig.AppsDomain = ""
+			Expect(fieldsOf(helpers.ValidateConfigFor(&cfg, helpers.RequireCF))).To(Equal([]string{"apps_domain"}))
+
+			cfg.CFConfig = nil
+			Expect(fieldsOf(helpers.ValidateConfigFor(&cfg, helpers.RequireCF))).To(Equal([]string{"standalone_only"}))
+		})
+
+		It("requires the broker credentials", func() {
+			cfg.BrokerUsername = "broker"
+
+			Expect(fieldsOf(helpers.ValidateConfigFor(&cfg, helpers.RequireBrokerCredentials))).To(Equal([]string{"broker_password"}))
+		})
+
+		It("requires the BOSH director settings", func() {
+			cfg.BOSH.URL = "192.168.50.6"
+
+			Expect(fieldsOf(helpers.ValidateConfigFor(&cfg, helpers.RequireBOSH))).To(Equal([]string{
+				"bosh.client",
+				"bosh.client_secret",
+			}))
+		})
+
+		It("requires a readable tuning expectation file", func() {
+			Expect(fieldsOf(helpers.ValidateConfigFor(&cfg, helpers.RequireTuning))).To(Equal([]string{"tuning.expectation_file_path"}))
+
+			cfg.Tuning.ExpectationFilePath = "/does/not/exist.json"
+			err := helpers.ValidateConfigFor(&cfg, helpers.RequireTuning)
+			Expect(err).To(MatchError(ContainSubstring("must point to a readable file")))
+
+			f, err := ioutil.TempFile("", "expectations")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.Remove(f.Name())
+			f.Close()
+
+			cfg.Tuning.ExpectationFilePath = f.Name()
+			Expect(helpers.ValidateConfigFor(&cfg, helpers.RequireTuning)).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,214 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+)
+
+const (
+	diagnosticsTimeout     = 60 * time.Second
+	defaultMySQLPort       = 3306
+	maxDiagnosticsDirChars = 100
+)
+
+var unsafePathChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// Diagnostics names the apps and service instances whose state is worth
+// keeping when a spec fails.
+type Diagnostics struct {
+	AppNames             []string
+	ServiceInstanceNames []string
+}
+
+var specDiagnostics struct {
+	sync.Mutex
+	Diagnostics
+	active    bool
+	collected bool
+}
+
+// DiagnoseOnFailure adds the app and service instance, either of which may
+// be empty, to the diagnostics collected if the running spec fails.
+func DiagnoseOnFailure(appName, serviceInstanceName string) {
+	specDiagnostics.Lock()
+	defer specDiagnostics.Unlock()
+
+	if appName != "" {
+		specDiagnostics.AppNames = append(specDiagnostics.AppNames, appName)
+	}
+	if serviceInstanceName != "" {
+		specDiagnostics.ServiceInstanceNames = append(specDiagnostics.ServiceInstanceNames, serviceInstanceName)
+	}
+}
+
+func resetDiagnostics() {
+	specDiagnostics.Lock()
+	defer specDiagnostics.Unlock()
+
+	specDiagnostics.Diagnostics = Diagnostics{}
+	specDiagnostics.active = true
+	specDiagnostics.collected = false
+}
+
+// failWithDiagnostics is the gomega fail handler of the suites. It runs at
+// the point of failure, before any AfterEach cleans up the spec's
+// resources, and collects the diagnostics of the spec once.
+func failWithDiagnostics(message string, callerSkip ...int) {
+	specDiagnostics.Lock()
+	collect := specDiagnostics.active && !specDiagnostics.collected
+	specDiagnostics.collected = true
+	diagnostics := specDiagnostics.Diagnostics
+	specDiagnostics.Unlock()
+
+	if collect {
+		dir := diagnosticsDir(TestConfig, CurrentGinkgoTestDescription().FullTestText)
+		if err := diagnostics.Collect(dir, TestConfig, message); err != nil {
+			fmt.Fprintf(GinkgoWriter, "Collecting diagnostics failed: %s\n", err.Error())
+		} else {
+			fmt.Fprintf(GinkgoWriter, "Diagnostics written to %s\n", dir)
+		}
+	}
+
+	skip := 0
+	if len(callerSkip) > 0 {
+		skip = callerSkip[0]
+	}
+
+	Fail(message, skip+1)
+}
+
+// diagnosticsDir is a directory for the spec under the artifacts directory,
+// ../results unless artifacts_directory is configured.
+func diagnosticsDir(cfg MysqlIntegrationConfig, specText string) string {
+	artifacts := filepath.Join("..", "results")
+	if cfg.CFConfig != nil && cfg.CFConfig.ArtifactsDirectory != "" {
+		artifacts = cfg.CFConfig.ArtifactsDirectory
+	}
+
+	name := strings.Trim(unsafePathChars.ReplaceAllString(specText, "-"), "-")
+	if len(name) > maxDiagnosticsDirChars {
+		name = name[:maxDiagnosticsDirChars]
+	}
+
+	return filepath.Join(artifacts, "diagnostics", fmt.Sprintf("%s-%s", name, time.Now().Format("20060102T150405")))
+}
+
+// Collect writes everything there is to know about the spec's resources
+// and the cluster into dir, one file per source. A source that cannot be
+// read leaves its error in its file rather than stopping the collection.
+func (d Diagnostics) Collect(dir string, cfg MysqlIntegrationConfig, failure string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	write := func(name string, contents []byte) {
+		path := filepath.Join(dir, unsafePathChars.ReplaceAllString(name, "-"))
+		if err := ioutil.WriteFile(path, contents, 0644); err != nil {
+			fmt.Fprintf(GinkgoWriter, "Writing %s: %s\n", path, err.Error())
+		}
+	}
+
+	write("failure.txt", []byte(failure+"\n"))
+
+	for _, app := range d.AppNames {
+		write("app-"+app+".txt", cfOutput("app", app))
+		write("app-"+app+"-logs.txt", cfOutput("logs", app, "--recent"))
+	}
+
+	for _, instance := range d.ServiceInstanceNames {
+		write("service-"+instance+".txt", cfOutput("service", instance))
+		write("service-"+instance+".json", cfOutput("curl", "/v3/service_instances?names="+url.QueryEscape(instance)))
+	}
+
+	for i, dashboardURL := range cfg.Proxy.DashboardUrls {
+		proxy := NewProxyAPIClient(cfg.Proxy, dashboardURL)
+		proxy.client.Timeout = diagnosticsTimeout
+		write(fmt.Sprintf("proxy-%d-cluster.json", i), jsonOutput(proxy.Cluster()))
+	}
+
+	if cfg.BrokerHost != "" && cfg.BrokerUsername != "" && cfg.BrokerPassword != "" {
+		broker := NewBrokerClient(cfg)
+		broker.client.Timeout = diagnosticsTimeout
+		write("broker-catalog.json", jsonOutput(broker.Catalog()))
+	}
+
+	if cfg.Standalone.MySQLUsername != "" {
+		port := cfg.Standalone.Port
+		if port == 0 {
+			port = defaultMySQLPort
+		}
+
+		for i, node := range cfg.MysqlNodes {
+			write(fmt.Sprintf("mysql-node-%d-wsrep.txt", i), wsrepStatus(node.Ip, port, cfg.Standalone))
+		}
+	}
+
+	return nil
+}
+
+func cfOutput(args ...string) []byte {
+	session, _ := RunCf(diagnosticsTimeout, args...)
+
+	var output bytes.Buffer
+	fmt.Fprintf(&output, "$ cf %s\n", strings.Join(args, " "))
+	output.Write(session.Out.Contents())
+	output.Write(session.Err.Contents())
+	fmt.Fprintf(&output, "exit status %d\n", session.ExitCode())
+
+	return output.Bytes()
+}
+
+// jsonOutput returns what a client decoded, or why it could not.
+func jsonOutput(value interface{}, err error) []byte {
+	if err != nil {
+		return []byte(err.Error() + "\n")
+	}
+
+	buf, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return []byte(err.Error() + "\n")
+	}
+
+	return append(buf, '\n')
+}
+
+func wsrepStatus(host string, port int, standalone Standalone) []byte {
+	db, err := openMySQL(host, port, standalone)
+	if err != nil {
+		return []byte(err.Error() + "\n")
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SHOW STATUS LIKE 'wsrep%'")
+	if err != nil {
+		return []byte(err.Error() + "\n")
+	}
+	defer rows.Close()
+
+	var output bytes.Buffer
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return append(output.Bytes(), []byte(err.Error()+"\n")...)
+		}
+
+		fmt.Fprintf(&output, "%s\t%s\n", name, value)
+	}
+
+	if err := rows.Err(); err != nil {
+		output.WriteString(err.Error() + "\n")
+	}
+
+	return output.Bytes()
+}
@@ -0,0 +1,159 @@
+package helpers_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
+)
+
+var _ = Describe("Diagnostics", func() {
+	var (
+		tmpDir       string
+		server       *httptest.Server
+		mysqlServer  *fakeMySQLServer
+		cfg          helpers.MysqlIntegrationConfig
+		diagnostics  helpers.Diagnostics
+		originalCf   func(args ...string) *gexec.Session
+		failCfOnArgs string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "diagnostics")
+		Expect(err).NotTo(HaveOccurred())
+
+		// stand in for the cf CLI with a command that echoes its arguments
+		failCfOnArgs = ""
+		originalCf = cf.Cf
+		cf.Cf = func(args ...string) *gexec.Session {
+			script := `echo "$@"`
+			if fmt.Sprint(args) == failCfOnArgs {
+				script = `echo "$@" >&2; exit 1`
+			}
+
+			session, err := gexec.Start(exec.Command("sh", append([]string{"-c", script, "cf"}, args...)...), GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			return session
+		}
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			username, password, _ := r.BasicAuth()
+			switch {
+			case r.URL.Path == "/v0/cluster" && username == "proxy-user" && password == "proxy-secret":
+				fmt.Fprint(w, `{"currentBackendIndex": 1, "trafficEnabled": true}`)
+			case r.URL.Path == "/v2/catalog" && username == "broker-user" && password == "broker-secret":
+				fmt.Fprint(w, `{"services": [{"name": "p-mysql"}]}`)
+			default:
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}))
+
+		mysqlServer = newFakeMySQLServer("root", "mysql-secret")
+		mysqlServer.status = [][2]string{
+			{"wsrep_cluster_size", "3"},
+			{"wsrep_local_state_comment", "Synced"},
+		}
+
+		mysqlHost, mysqlPort, err := net.SplitHostPort(mysqlServer.Addr())
+		Expect(err).NotTo(HaveOccurred())
+		port, err := strconv.Atoi(mysqlPort)
+		Expect(err).NotTo(HaveOccurred())
+
+		cfg = helpers.MysqlIntegrationConfig{
+			CFConfig:       &config.Config{},
+			BrokerHost:     server.Listener.Addr().String(),
+			BrokerProtocol: "http",
+			BrokerUsername: "broker-user",
+			BrokerPassword: "broker-secret",
+			Proxy: helpers.Proxy{
+				DashboardUrls: []string{server.URL},
+				APIUsername:   "proxy-user",
+				APIPassword:   "proxy-secret",
+			},
+			MysqlNodes: []helpers.Component{{Ip: mysqlHost}},
+			Standalone: helpers.Standalone{
+				Port:          port,
+				MySQLUsername: "root",
+				MySQLPassword: "mysql-secret",
+			},
+		}
+
+		diagnostics = helpers.Diagnostics{
+			AppNames:             []string{"MySQLATS-1-LIFECYCLE-APP-abc"},
+			ServiceInstanceNames: []string{"MySQLATS-1-LIFECYCLE-INSTANCE-def"},
+		}
+	})
+
+	AfterEach(func() {
+		cf.Cf = originalCf
+		server.Close()
+		mysqlServer.Close()
+		os.RemoveAll(tmpDir)
+	})
+
+	readFile := func(name string) string {
+		contents, err := ioutil.ReadFile(filepath.Join(tmpDir, "bundle", name))
+		Expect(err).NotTo(HaveOccurred())
+		return string(contents)
+	}
+
+	It("writes the state of the spec's resources and the cluster into the directory", func() {
+		Expect(diagnostics.Collect(filepath.Join(tmpDir, "bundle"), cfg, "Expected exit code 0")).To(Succeed())
+
+		Expect(readFile("failure.txt")).To(Equal("Expected exit code 0\n"))
+		Expect(readFile("app-MySQLATS-1-LIFECYCLE-APP-abc.txt")).To(Equal(
+			"$ cf app MySQLATS-1-LIFECYCLE-APP-abc\napp MySQLATS-1-LIFECYCLE-APP-abc\nexit status 0\n"))
+		Expect(readFile("app-MySQLATS-1-LIFECYCLE-APP-abc-logs.txt")).To(ContainSubstring("logs MySQLATS-1-LIFECYCLE-APP-abc --recent"))
+		Expect(readFile("service-MySQLATS-1-LIFECYCLE-INSTANCE-def.txt")).To(ContainSubstring("service MySQLATS-1-LIFECYCLE-INSTANCE-def"))
+		Expect(readFile("service-MySQLATS-1-LIFECYCLE-INSTANCE-def.json")).To(ContainSubstring("curl /v3/service_instances?names=MySQLATS-1-LIFECYCLE-INSTANCE-def"))
+		Expect(readFile("proxy-0-cluster.json")).To(ContainSubstring(`"currentBackendIndex": 1,`))
+		Expect(readFile("proxy-0-cluster.json")).To(ContainSubstring(`"trafficEnabled": true,`))
+		Expect(readFile("broker-catalog.json")).To(ContainSubstring(`"name": "p-mysql",`))
+		Expect(readFile("mysql-node-0-wsrep.txt")).To(Equal("wsrep_cluster_size\t3\nwsrep_local_state_comment\tSynced\n"))
+	})
+
+	It("keeps what a failing source returned", func() {
+		failCfOnArgs = "[app MySQLATS-1-LIFECYCLE-APP-abc]"
+		cfg.Proxy.APIPassword = "wrong"
+		cfg.Standalone.MySQLPassword = "wrong"
+
+		Expect(diagnostics.Collect(filepath.Join(tmpDir, "bundle"), cfg, "Expected exit code 0")).To(Succeed())
+
+		Expect(readFile("app-MySQLATS-1-LIFECYCLE-APP-abc.txt")).To(HaveSuffix("app MySQLATS-1-LIFECYCLE-APP-abc\nexit status 1\n"))
+		Expect(readFile("proxy-0-cluster.json")).To(ContainSubstring("returned 401 Unauthorized"))
+		Expect(readFile("mysql-node-0-wsrep.txt")).To(ContainSubstring("Access denied"))
+		Expect(readFile("broker-catalog.json")).To(ContainSubstring("p-mysql"))
+	})
+
+	It("leaves out the sources without credentials", func() {
+		cfg.BrokerPassword = ""
+		cfg.Standalone = helpers.Standalone{}
+
+		Expect(diagnostics.Collect(filepath.Join(tmpDir, "bundle"), cfg, "Expected exit code 0")).To(Succeed())
+
+		files, err := ioutil.ReadDir(filepath.Join(tmpDir, "bundle"))
+		Expect(err).NotTo(HaveOccurred())
+
+		var names []string
+		for _, file := range files {
+			names = append(names, file.Name())
+		}
+		Expect(names).NotTo(ContainElement("broker-catalog.json"))
+		Expect(names).NotTo(ContainElement("mysql-node-0-wsrep.txt"))
+		Expect(names).To(ContainElement("proxy-0-cluster.json"))
+	})
+})
@@ -0,0 +1,306 @@
+package helpers
+
+import (
+	"crypto/tls"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/config"
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// PreflightEnv enables the preflight checks at the start of every suite.
+const PreflightEnv = EnvPrefix + "PREFLIGHT"
+
+// PreflightExitCode is the exit status when an endpoint in the config cannot
+// be reached or rejects its credentials. It matches EX_UNAVAILABLE from
+// sysexits.h.
+const PreflightExitCode = 69
+
+const (
+	preflightTimeout = 10 * time.Second
+	brokerAPIVersion = "2.11"
+	cfOAuthClient    = "cf"
+	mysqlPacketLimit = 4 << 20
+)
+
+// CheckResult is the outcome of one preflight check. Skipped holds the reason
+// a check could not be attempted.
+type CheckResult struct {
+	Check   string
+	Target  string
+	Err     error
+	Skipped string
+}
+
+func (r CheckResult) Passed() bool {
+	return r.Err == nil
+}
+
+type preflightCheck struct {
+	name   string
+	target string
+	skip   string
+	run    func() error
+}
+
+// Preflight checks that every endpoint in the config can be reached with the
+// configured credentials: the CF API login, the broker catalog, the proxy
+// dashboards, the BOSH director's UAA and the standalone MySQL server.
+// Endpoints missing from the config are not checked.
+func Preflight(cfg MysqlIntegrationConfig) []CheckResult {
+	var results []CheckResult
+	for _, check := range preflightChecks(cfg) {
+		result := CheckResult{Check: check.name, Target: check.target, Skipped: check.skip}
+		if check.skip == "" {
+			result.Err = check.run()
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// PreflightPassed reports whether none of the checks failed.
+func PreflightPassed(results []CheckResult) bool {
+	for _, result := range results {
+		if !result.Passed() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// WriteCheckResults prints the results as a table.
+func WriteCheckResults(w io.Writer, results []CheckResult) {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "CHECK\tTARGET\tRESULT")
+
+	for _, result := range results {
+		outcome := "pass"
+		switch {
+		case result.Err != nil:
+			outcome = "FAIL: " + result.Err.Error()
+		case result.Skipped != "":
+			outcome = "skipped: " + result.Skipped
+		}
+
+		fmt.Fprintf(table, "%s\t%s\t%s\n", result.Check, result.Target, outcome)
+	}
+
+	table.Flush()
+}
+
+func preflightChecks(cfg MysqlIntegrationConfig) []preflightCheck {
+	var checks []preflightCheck
+
+	if cfg.CFConfig != nil && cfg.CFConfig.ApiEndpoint != "" {
+		cfConfig := cfg.CFConfig
+		checks = append(checks, preflightCheck{
+			name:   "CF API login",
+			target: cfAPIURL(cfConfig.ApiEndpoint),
+			run:    func() error { return checkCFLogin(cfConfig) },
+		})
+	}
+
+	if cfg.BrokerHost != "" {
+		check := preflightCheck{
+			name:   "Broker catalog",
+			target: brokerCatalogURL(cfg),
+			run:    func() error { return checkBrokerCatalog(cfg) },
+		}
+		if cfg.BrokerUsername == "" || cfg.BrokerPassword == "" {
+			check.skip = "broker_username and broker_password are not set"
+		}
+
+		checks = append(checks, check)
+	}
+
+	for _, dashboardURL := range cfg.Proxy.DashboardUrls {
+		dashboardURL := dashboardURL
+		checks = append(checks, preflightCheck{
+			name:   "Proxy dashboard",
+			target: dashboardURL,
+			run:    func() error { return checkProxyDashboard(cfg.Proxy, dashboardURL) },
+		})
+	}
+
+	if cfg.BOSH.URL != "" {
+		checks = append(checks, preflightCheck{
+			name:   "BOSH UAA",
+			target: cfg.BOSH.uaaURL(),
+			run:    func() error { return checkBOSHUAA(cfg.BOSH) },
+		})
+	}
+
+	if cfg.Standalone.Host != "" {
+		checks = append(checks, preflightCheck{
+			name:   "MySQL ping",
+			target: fmt.Sprintf("%s:%d", cfg.Standalone.Host, cfg.Standalone.Port),
+			run:    func() error { return checkMySQL(cfg.Standalone) },
+		})
+	}
+
+	return checks
+}
+
+func newHTTPClient(skipSSLValidation bool, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: skipSSLValidation},
+		},
+	}
+}
+
+// cfAPIURL accepts the API endpoint with or without a scheme, like the cf CLI.
+func cfAPIURL(endpoint string) string {
+	if strings.HasPrefix(endpoint, "http://") || strings.HasPrefix(endpoint, "https://") {
+		return strings.TrimSuffix(endpoint, "/")
+	}
+
+	return "https://" + strings.TrimSuffix(endpoint, "/")
+}
+
+func checkStatus(resp *http.Response, expected int) error {
+	if resp.StatusCode != expected {
+		return fmt.Errorf("%s %s returned %s", resp.Request.Method, resp.Request.URL, resp.Status)
+	}
+
+	return nil
+}
+
+func checkCFLogin(cfConfig *config.Config) error {
+	_, err := cfLogin(newHTTPClient(cfConfig.SkipSSLValidation, preflightTimeout), cfConfig)
+	return err
+}
+
+// cfLogin logs the admin user in with the UAA that the CF API advertises,
+// like `cf auth`, and returns the access token.
+func cfLogin(client *http.Client, cfConfig *config.Config) (string, error) {
+	resp, err := client.Get(cfAPIURL(cfConfig.ApiEndpoint) + "/v2/info")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp, http.StatusOK); err != nil {
+		return "", err
+	}
+
+	var info struct {
+		TokenEndpoint string `json:"token_endpoint"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return "", fmt.Errorf("Decoding /v2/info: %s", err.Error())
+	}
+
+	if info.TokenEndpoint == "" {
+		return "", fmt.Errorf("/v2/info does not list a token_endpoint")
+	}
+
+	form := url.Values{
+		"grant_type": {"password"},
+		"username":   {cfConfig.AdminUser},
+		"password":   {cfConfig.AdminPassword},
+	}
+
+	req, err := http.NewRequest("POST", strings.TrimSuffix(info.TokenEndpoint, "/")+"/oauth/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(cfOAuthClient, "")
+
+	tokenResp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer tokenResp.Body.Close()
+
+	if tokenResp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("UAA did not log in '%s': %s", cfConfig.AdminUser, tokenResp.Status)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(tokenResp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("Decoding the UAA token: %s", err.Error())
+	}
+
+	return token.AccessToken, nil
+}
+
+func brokerCatalogURL(cfg MysqlIntegrationConfig) string {
+	return fmt.Sprintf("%s://%s/v2/catalog", cfg.BrokerProtocol, cfg.BrokerHost)
+}
+
+func checkBrokerCatalog(cfg MysqlIntegrationConfig) error {
+	client := NewBrokerClient(cfg)
+	client.client.Timeout = preflightTimeout
+
+	catalog, err := client.Catalog()
+	if err != nil {
+		return err
+	}
+
+	if len(catalog.Services) == 0 {
+		return fmt.Errorf("The catalog does not offer any services")
+	}
+
+	return nil
+}
+
+func checkProxyDashboard(proxy Proxy, dashboardURL string) error {
+	client := NewProxyAPIClient(proxy, dashboardURL)
+	client.client.Timeout = preflightTimeout
+
+	_, err := client.Cluster()
+	return err
+}
+
+func checkBOSHUAA(boshConfig BOSH) error {
+	uaa, err := buildUAA(boshConfig)
+	if err != nil {
+		return err
+	}
+
+	_, err = uaa.ClientCredentialsGrant()
+	return err
+}
+
+func checkMySQL(standalone Standalone) error {
+	db, err := openMySQL(standalone.Host, standalone.Port, standalone)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Ping()
+}
+
+// openMySQL connects to host with the standalone credentials.
+func openMySQL(host string, port int, standalone Standalone) (*sql.DB, error) {
+	// neither a ping nor SHOW STATUS needs the server's max_allowed_packet, so don't query it
+	connectionString := fmt.Sprintf("%s:%s@tcp(%s:%d)/?timeout=%s&maxAllowedPacket=%d",
+		standalone.MySQLUsername,
+		standalone.MySQLPassword,
+		host,
+		port,
+		preflightTimeout,
+		mysqlPacketLimit)
+
+	return sql.Open("mysql", connectionString)
+}
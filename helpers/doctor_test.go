@@ -0,0 +1,467 @@
+package helpers_test
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
+)
+
+var _ = Describe("Preflight", func() {
+	var (
+		cfServer     *httptest.Server
+		brokerServer *httptest.Server
+		proxyServer  *httptest.Server
+		uaaServer    *httptest.Server
+		mysqlServer  *fakeMySQLServer
+		catalog      string
+		cfg          helpers.MysqlIntegrationConfig
+	)
+
+	requireBasicAuth := func(w http.ResponseWriter, r *http.Request, username, password string) bool {
+		if u, p, ok := r.BasicAuth(); !ok || u != username || p != password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return false
+		}
+
+		return true
+	}
+
+	BeforeEach(func() {
+		catalog = `{"services": [{"name": "p-mysql"}]}`
+
+		cfServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/v2/info":
+				json.NewEncoder(w).Encode(map[string]string{"token_endpoint": cfServer.URL})
+			case "/oauth/token":
+				if !requireBasicAuth(w, r, "cf", "") {
+					return
+				}
+
+				if r.PostFormValue("grant_type") != "password" || r.PostFormValue("username") != "admin" || r.PostFormValue("password") != "admin-secret" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+
+				fmt.Fprint(w, `{"access_token": "cf-token", "token_type": "bearer"}`)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		brokerServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/v2/catalog" || r.Header.Get("X-Broker-API-Version") == "" {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+
+			if requireBasicAuth(w, r, "broker-user", "broker-secret") {
+				fmt.Fprint(w, catalog)
+			}
+		}))
+
+		// serves the cluster of every proxy, under their dashboard paths
+		proxyServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasSuffix(r.URL.Path, "/v0/cluster") {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			if requireBasicAuth(w, r, "proxy-user", "proxy-secret") {
+				fmt.Fprint(w, `{"currentBackendIndex": 0}`)
+			}
+		}))
+
+		uaaServer = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/oauth/token" || !requireBasicAuth(w, r, "admin", "bosh-secret") {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"access_token": "bosh-token", "token_type": "bearer", "expires_in": 3600}`)
+		}))
+
+		mysqlServer = newFakeMySQLServer("root", "mysql-secret")
+
+		mysqlHost, mysqlPort, err := net.SplitHostPort(mysqlServer.Addr())
+		Expect(err).NotTo(HaveOccurred())
+		port, err := strconv.Atoi(mysqlPort)
+		Expect(err).NotTo(HaveOccurred())
+
+		cfg = helpers.MysqlIntegrationConfig{
+			CFConfig: &config.Config{
+				ApiEndpoint:   cfServer.URL,
+				AdminUser:     "admin",
+				AdminPassword: "admin-secret",
+			},
+			BrokerHost:     brokerServer.Listener.Addr().String(),
+			BrokerProtocol: "http",
+			BrokerUsername: "broker-user",
+			BrokerPassword: "broker-secret",
+			Proxy: helpers.Proxy{
+				DashboardUrls: []string{proxyServer.URL + "/proxy-0", proxyServer.URL + "/proxy-1"},
+				APIUsername:   "proxy-user",
+				APIPassword:   "proxy-secret",
+			},
+			BOSH: helpers.BOSH{
+				URL:          "127.0.0.1",
+				UAAURL:       uaaServer.URL,
+				Client:       "admin",
+				ClientSecret: "bosh-secret",
+				CACert: string(pem.EncodeToMemory(&pem.Block{
+					Type:  "CERTIFICATE",
+					Bytes: uaaServer.Certificate().Raw,
+				})),
+			},
+			Standalone: helpers.Standalone{
+				Host:          mysqlHost,
+				Port:          port,
+				MySQLUsername: "root",
+				MySQLPassword: "mysql-secret",
+			},
+		}
+	})
+
+	AfterEach(func() {
+		cfServer.Close()
+		brokerServer.Close()
+		proxyServer.Close()
+		uaaServer.Close()
+		mysqlServer.Close()
+	})
+
+	resultsByTarget := func(results []helpers.CheckResult) map[string]helpers.CheckResult {
+		byTarget := map[string]helpers.CheckResult{}
+		for _, result := range results {
+			byTarget[result.Target] = result
+		}
+
+		return byTarget
+	}
+
+	It("passes when every endpoint accepts its credentials", func() {
+		results := helpers.Preflight(cfg)
+
+		var checks []string
+		for _, result := range results {
+			Expect(result.Err).NotTo(HaveOccurred(), result.Check)
+			checks = append(checks, result.Check)
+		}
+
+		Expect(checks).To(Equal([]string{"CF API login", "Broker catalog", "Proxy dashboard", "Proxy dashboard", "BOSH UAA", "MySQL ping"}))
+		Expect(helpers.PreflightPassed(results)).To(BeTrue())
+	})
+
+	It("reports every endpoint that rejects its credentials", func() {
+		cfg.CFConfig.AdminPassword = "wrong"
+		cfg.BrokerPassword = "wrong"
+		cfg.Proxy.APIPassword = "wrong"
+		cfg.BOSH.ClientSecret = "wrong"
+		cfg.Standalone.MySQLPassword = "wrong"
+
+		results := helpers.Preflight(cfg)
+		Expect(helpers.PreflightPassed(results)).To(BeFalse())
+
+		for _, result := range results {
+			Expect(result.Err).To(HaveOccurred(), result.Check)
+		}
+
+		byTarget := resultsByTarget(results)
+		Expect(byTarget[cfServer.URL].Err).To(MatchError(ContainSubstring("UAA did not log in 'admin': 401")))
+		Expect(byTarget["http://"+cfg.BrokerHost+"/v2/catalog"].Err).To(MatchError(ContainSubstring("returned 401")))
+		Expect(byTarget[cfg.Proxy.DashboardUrls[0]].Err).To(MatchError(ContainSubstring("returned 401")))
+		Expect(byTarget[mysqlServer.Addr()].Err).To(MatchError(ContainSubstring("Access denied for user 'root'")))
+	})
+
+	It("reports endpoints that cannot be reached", func() {
+		proxyServer.Close()
+		mysqlServer.Close()
+
+		byTarget := resultsByTarget(helpers.Preflight(cfg))
+		Expect(byTarget[cfg.Proxy.DashboardUrls[0]].Err).To(HaveOccurred())
+		Expect(byTarget[cfg.Proxy.DashboardUrls[1]].Err).To(HaveOccurred())
+		Expect(byTarget[mysqlServer.Addr()].Err).To(HaveOccurred())
+		Expect(byTarget[cfServer.URL].Passed()).To(BeTrue())
+	})
+
+	It("rejects a BOSH UAA whose certificate is not signed by the configured CA", func() {
+		cfg.BOSH.CACert = ""
+
+		byTarget := resultsByTarget(helpers.Preflight(cfg))
+		Expect(byTarget[uaaServer.URL].Err).To(HaveOccurred())
+	})
+
+	It("fails the broker check when the catalog is empty", func() {
+		catalog = `{"services": []}`
+
+		byTarget := resultsByTarget(helpers.Preflight(cfg))
+		Expect(byTarget["http://"+cfg.BrokerHost+"/v2/catalog"].Err).To(MatchError("The catalog does not offer any services"))
+	})
+
+	It("skips the broker check without broker credentials and leaves out unconfigured endpoints", func() {
+		cfg.BrokerPassword = ""
+		cfg.Proxy.DashboardUrls = nil
+		cfg.BOSH = helpers.BOSH{}
+		cfg.Standalone = helpers.Standalone{}
+
+		results := helpers.Preflight(cfg)
+		Expect(results).To(HaveLen(2))
+		Expect(results[1].Check).To(Equal("Broker catalog"))
+		Expect(results[1].Skipped).To(Equal("broker_username and broker_password are not set"))
+		Expect(helpers.PreflightPassed(results)).To(BeTrue())
+	})
+
+	It("prints the results as a table", func() {
+		buffer := gbytes.NewBuffer()
+		helpers.WriteCheckResults(buffer, []helpers.CheckResult{
+			{Check: "CF API login", Target: "https://api.example.com"},
+			{Check: "Broker catalog", Target: "https://broker.example.com/v2/catalog", Skipped: "no credentials"},
+			{Check: "MySQL ping", Target: "10.0.0.1:3306", Err: errors.New("connection refused")},
+		})
+
+		Expect(string(buffer.Contents())).To(Equal(`CHECK           TARGET                                 RESULT
+CF API login    https://api.example.com                pass
+Broker catalog  https://broker.example.com/v2/catalog  skipped: no credentials
+MySQL ping      10.0.0.1:3306                          FAIL: connection refused
+`))
+	})
+})
+
+// fakeMySQLServer speaks just enough of the MySQL protocol for a client to
+// authenticate with mysql_native_password, ping and run a query, which
+// returns the two-column status rows.
+type fakeMySQLServer struct {
+	listener net.Listener
+	username string
+	password string
+	status   [][2]string
+}
+
+func newFakeMySQLServer(username, password string) *fakeMySQLServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).NotTo(HaveOccurred())
+
+	server := &fakeMySQLServer{listener: listener, username: username, password: password}
+	go server.serve()
+
+	return server
+}
+
+func (s *fakeMySQLServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *fakeMySQLServer) Close() {
+	s.listener.Close()
+}
+
+func (s *fakeMySQLServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go s.handle(conn)
+	}
+}
+
+const (
+	mysqlClientProtocol41   = 0x0200
+	mysqlClientSecureConn   = 0x8000
+	mysqlClientPluginAuth   = 0x00080000
+	mysqlAccessDeniedError  = 1045
+	mysqlComQuit            = 0x01
+	mysqlComQuery           = 0x03
+	mysqlComPing            = 0x0e
+	mysqlTypeVarString      = 0xfd
+	mysqlNativePasswordName = "mysql_native_password"
+)
+
+func (s *fakeMySQLServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	scramble := []byte("abcdefghijklmnopqrst")
+	capabilities := uint32(mysqlClientProtocol41 | mysqlClientSecureConn | mysqlClientPluginAuth)
+
+	var greeting bytes.Buffer
+	greeting.WriteByte(10)
+	greeting.WriteString("5.7.0-fake\x00")
+	binary.Write(&greeting, binary.LittleEndian, uint32(1))
+	greeting.Write(scramble[:8])
+	greeting.WriteByte(0)
+	binary.Write(&greeting, binary.LittleEndian, uint16(capabilities))
+	greeting.WriteByte(33)
+	binary.Write(&greeting, binary.LittleEndian, uint16(2))
+	binary.Write(&greeting, binary.LittleEndian, uint16(capabilities>>16))
+	greeting.WriteByte(21)
+	greeting.Write(make([]byte, 10))
+	greeting.Write(scramble[8:])
+	greeting.WriteByte(0)
+	greeting.WriteString(mysqlNativePasswordName + "\x00")
+
+	if writeMySQLPacket(conn, 0, greeting.Bytes()) != nil {
+		return
+	}
+
+	seq, auth, err := readMySQLPacket(conn)
+	if err != nil || len(auth) < 32 {
+		return
+	}
+
+	// capabilities, max packet size, character set and filler come first
+	auth = auth[32:]
+	userEnd := bytes.IndexByte(auth, 0)
+	if userEnd < 0 || userEnd+1 >= len(auth) {
+		return
+	}
+	username := string(auth[:userEnd])
+	responseLength := int(auth[userEnd+1])
+	response := auth[userEnd+2:]
+	if responseLength > len(response) {
+		return
+	}
+	response = response[:responseLength]
+
+	if username != s.username || !bytes.Equal(response, nativePasswordResponse(scramble, s.password)) {
+		message := fmt.Sprintf("Access denied for user '%s'@'localhost' (using password: YES)", username)
+		var errPacket bytes.Buffer
+		errPacket.WriteByte(0xff)
+		binary.Write(&errPacket, binary.LittleEndian, uint16(mysqlAccessDeniedError))
+		errPacket.WriteString("#28000" + message)
+		writeMySQLPacket(conn, seq+1, errPacket.Bytes())
+		return
+	}
+
+	if writeMySQLOK(conn, seq+1) != nil {
+		return
+	}
+
+	for {
+		_, command, err := readMySQLPacket(conn)
+		if err != nil || len(command) == 0 {
+			return
+		}
+
+		switch command[0] {
+		case mysqlComPing:
+			if writeMySQLOK(conn, 1) != nil {
+				return
+			}
+		case mysqlComQuery:
+			if s.writeStatus(conn) != nil {
+				return
+			}
+		case mysqlComQuit:
+			return
+		default:
+			return
+		}
+	}
+}
+
+func (s *fakeMySQLServer) writeStatus(conn net.Conn) error {
+	seq := byte(1)
+	write := func(payload []byte) error {
+		err := writeMySQLPacket(conn, seq, payload)
+		seq++
+		return err
+	}
+
+	if err := write([]byte{2}); err != nil {
+		return err
+	}
+
+	for _, column := range []string{"Variable_name", "Value"} {
+		var definition bytes.Buffer
+		for _, field := range []string{"def", "", "", "", column, column} {
+			definition.Write(mysqlLengthEncodedString(field))
+		}
+		definition.WriteByte(0x0c)
+		binary.Write(&definition, binary.LittleEndian, uint16(33))
+		binary.Write(&definition, binary.LittleEndian, uint32(1024))
+		definition.WriteByte(mysqlTypeVarString)
+		definition.Write([]byte{0, 0, 0, 0, 0})
+
+		if err := write(definition.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	if err := write(mysqlEOF()); err != nil {
+		return err
+	}
+
+	for _, row := range s.status {
+		if err := write(append(mysqlLengthEncodedString(row[0]), mysqlLengthEncodedString(row[1])...)); err != nil {
+			return err
+		}
+	}
+
+	return write(mysqlEOF())
+}
+
+func mysqlLengthEncodedString(value string) []byte {
+	return append([]byte{byte(len(value))}, value...)
+}
+
+func mysqlEOF() []byte {
+	// warnings and status flags
+	return []byte{0xfe, 0x00, 0x00, 0x02, 0x00}
+}
+
+func nativePasswordResponse(scramble []byte, password string) []byte {
+	stage1 := sha1.Sum([]byte(password))
+	stage2 := sha1.Sum(stage1[:])
+	response := sha1.Sum(append(append([]byte{}, scramble...), stage2[:]...))
+	for i := range response {
+		response[i] ^= stage1[i]
+	}
+
+	return response[:]
+}
+
+func readMySQLPacket(conn net.Conn) (byte, []byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return 0, nil, err
+	}
+
+	length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return 0, nil, err
+	}
+
+	return header[3], payload, nil
+}
+
+func writeMySQLPacket(conn net.Conn, seq byte, payload []byte) error {
+	length := len(payload)
+	header := []byte{byte(length), byte(length >> 8), byte(length >> 16), seq}
+	_, err := conn.Write(append(header, payload...))
+	return err
+}
+
+func writeMySQLOK(conn net.Conn, seq byte) error {
+	// affected rows, last insert id, status flags and warnings
+	return writeMySQLPacket(conn, seq, []byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00})
+}
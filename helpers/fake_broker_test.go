@@ -0,0 +1,127 @@
+package helpers_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+const (
+	fakeBrokerUsername = "broker-user"
+	fakeBrokerPassword = "broker-secret"
+	fakeBrokerCatalog  = `{"services": [{
+		"id": "service-1", "name": "p-mysql", "description": "MySQL databases on demand",
+		"bindable": true, "plan_updateable": true, "tags": ["mysql"],
+		"plans": [
+			{"id": "plan-1", "name": "10mb", "description": "Maximum 10MB storage", "free": true,
+			 "metadata": {"displayName": "10 MB", "bullets": ["10 MB storage", "40 concurrent connections"], "max_storage_mb": 10, "max_user_connections": 40}},
+			{"id": "plan-2", "name": "100mb", "description": "Maximum 100MB storage",
+			 "metadata": {"bullets": ["100 MB storage"]}}
+		]
+	}]}`
+)
+
+// fakeBroker keeps its instances and bindings in memory and answers like
+// an OSB v2 broker: provisioning and binding the same thing again is fine,
+// with a different body it conflicts.
+type fakeBroker struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	instances map[string]map[string]interface{}
+	bindings  map[string]map[string]interface{}
+	headers   []http.Header
+}
+
+func newFakeBroker() *fakeBroker {
+	broker := &fakeBroker{
+		instances: map[string]map[string]interface{}{},
+		bindings:  map[string]map[string]interface{}{},
+	}
+	broker.Server = httptest.NewServer(http.HandlerFunc(broker.serveHTTP))
+	return broker
+}
+
+func (b *fakeBroker) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.headers = append(b.headers, r.Header)
+
+	if username, password, ok := r.BasicAuth(); !ok || username != fakeBrokerUsername || password != fakeBrokerPassword {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("HTTP Basic: Access denied.\n"))
+		return
+	}
+
+	if r.Header.Get("X-Broker-API-Version") == "" {
+		writeJSON(w, http.StatusPreconditionFailed, `{"description": "X-Broker-API-Version is missing"}`)
+		return
+	}
+
+	var body map[string]interface{}
+	if buf, _ := ioutil.ReadAll(r.Body); len(buf) > 0 {
+		json.Unmarshal(buf, &body)
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/v2/catalog":
+		writeJSON(w, http.StatusOK, fakeBrokerCatalog)
+	case len(parts) == 3 && r.Method == "PUT":
+		putOnce(w, b.instances, parts[2], body, `{"dashboard_url": "https://p-mysql.example.com/manage/instances/`+parts[2]+`"}`)
+	case len(parts) == 3 && r.Method == "PATCH":
+		if body["plan_id"] == "plan-1" {
+			writeJSON(w, http.StatusUnprocessableEntity, `{"error": "PlanChangeNotSupported", "description": "Cannot downgrade"}`)
+			return
+		}
+		writeJSON(w, http.StatusOK, `{}`)
+	case len(parts) == 3 && r.Method == "DELETE":
+		deleteOnce(w, b.instances, parts[2])
+	case len(parts) == 4 && parts[3] == "last_operation":
+		writeJSON(w, http.StatusOK, `{"state": "succeeded", "description": "`+r.URL.Query().Get("operation")+`"}`)
+	case len(parts) == 5 && r.Method == "PUT":
+		if _, found := b.instances[parts[2]]; !found {
+			writeJSON(w, http.StatusNotFound, `{}`)
+			return
+		}
+		putOnce(w, b.bindings, parts[4], body, `{"credentials": {"hostname": "10.244.7.2", "port": 3306}}`)
+	case len(parts) == 5 && r.Method == "DELETE":
+		deleteOnce(w, b.bindings, parts[4])
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func putOnce(w http.ResponseWriter, resources map[string]map[string]interface{}, id string, body map[string]interface{}, created string) {
+	existing, found := resources[id]
+	switch {
+	case !found:
+		resources[id] = body
+		writeJSON(w, http.StatusCreated, created)
+	case reflect.DeepEqual(existing, body):
+		writeJSON(w, http.StatusOK, created)
+	default:
+		writeJSON(w, http.StatusConflict, `{}`)
+	}
+}
+
+func deleteOnce(w http.ResponseWriter, resources map[string]map[string]interface{}, id string) {
+	if _, found := resources[id]; !found {
+		writeJSON(w, http.StatusGone, `{}`)
+		return
+	}
+
+	delete(resources, id)
+	writeJSON(w, http.StatusOK, `{}`)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write([]byte(body))
+}
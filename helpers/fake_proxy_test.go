@@ -0,0 +1,123 @@
+package helpers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
+)
+
+// fakeProxy serves the proxy API over TLS, like a proxy with
+// api_force_https, for the backends it is given.
+type fakeProxy struct {
+	*httptest.Server
+
+	mu             sync.Mutex
+	backends       []helpers.ProxyBackend
+	trafficEnabled bool
+	message        string
+	backendMessage map[string]string
+	requests       []string
+}
+
+const (
+	fakeProxyUsername = "proxy-user"
+	fakeProxyPassword = "proxy-password"
+)
+
+func newFakeProxy(backends ...helpers.ProxyBackend) *fakeProxy {
+	for i := range backends {
+		backends[i].Enabled = true
+	}
+
+	proxy := &fakeProxy{backends: backends, trafficEnabled: true, backendMessage: map[string]string{}}
+	proxy.Server = httptest.NewTLSServer(http.HandlerFunc(proxy.serveHTTP))
+	return proxy
+}
+
+// activate makes the named backend the one connections are routed to.
+func (p *fakeProxy) activate(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i := range p.backends {
+		p.backends[i].Active = p.backends[i].Name == name
+	}
+}
+
+func (p *fakeProxy) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.requests = append(p.requests, r.Method+" "+r.URL.RequestURI())
+
+	if username, password, ok := r.BasicAuth(); !ok || username != fakeProxyUsername || password != fakeProxyPassword {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/v0/backends":
+		json.NewEncoder(w).Encode(p.backends)
+	case r.Method == "PATCH" && strings.HasPrefix(r.URL.Path, "/v0/backends/"):
+		name := strings.TrimPrefix(r.URL.Path, "/v0/backends/")
+		for i := range p.backends {
+			if p.backends[i].Name != name {
+				continue
+			}
+
+			switch r.URL.Query().Get("enabled") {
+			case "true":
+				p.backends[i].Enabled = true
+			case "false":
+				p.backends[i].Enabled = false
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			p.backendMessage[name] = r.URL.Query().Get("message")
+			json.NewEncoder(w).Encode(p.backends[i])
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	case r.Method == "GET" && r.URL.Path == "/v0/cluster":
+		json.NewEncoder(w).Encode(p.cluster())
+	case r.Method == "PATCH" && r.URL.Path == "/v0/cluster":
+		switch r.URL.Query().Get("trafficEnabled") {
+		case "true":
+			p.trafficEnabled = true
+		case "false":
+			p.trafficEnabled = false
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		p.message = r.URL.Query().Get("message")
+		json.NewEncoder(w).Encode(p.cluster())
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (p *fakeProxy) cluster() map[string]interface{} {
+	cluster := map[string]interface{}{
+		"activeBackend":       nil,
+		"currentBackendIndex": 0,
+		"trafficEnabled":      p.trafficEnabled,
+		"message":             p.message,
+		"lastUpdated":         time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	for i, backend := range p.backends {
+		if backend.Active {
+			cluster["activeBackend"] = backend
+			cluster["currentBackendIndex"] = i
+		}
+	}
+
+	return cluster
+}
@@ -1,19 +1,108 @@
 package helpers
 
+// MysqlManifest models the parts of a cf-mysql deployment manifest the
+// config generator cares about. Both the v1 shape (top-level jobs and global
+// properties) and the v2 shape (instance_groups, variables and job-level
+// properties) are supported.
 type MysqlManifest struct {
-	Jobs       []Job      `yaml:"jobs"`
-	Properties Properties `yaml:"properties"`
+	Name           string                      `yaml:"name"`
+	Jobs           []Job                       `yaml:"jobs"`
+	InstanceGroups []InstanceGroup             `yaml:"instance_groups"`
+	Variables      []Variable                  `yaml:"variables"`
+	Properties     map[interface{}]interface{} `yaml:"properties"`
 }
 
 type Job struct {
-	Instances int    `yaml:"instances"`
-	Name      string `yaml:"name"`
+	Instances int                `yaml:"instances"`
+	Name      string             `yaml:"name"`
+	Templates []InstanceGroupJob `yaml:"templates"`
+	Networks  []Network          `yaml:"networks"`
+}
+
+type InstanceGroup struct {
+	Instances int                `yaml:"instances"`
+	Name      string             `yaml:"name"`
+	Lifecycle string             `yaml:"lifecycle"`
+	Jobs      []InstanceGroupJob `yaml:"jobs"`
+	Networks  []Network          `yaml:"networks"`
+}
+
+type InstanceGroupJob struct {
+	Name       string                      `yaml:"name"`
+	Release    string                      `yaml:"release"`
+	Consumes   map[string]Link             `yaml:"consumes"`
+	Provides   map[string]Link             `yaml:"provides"`
+	Properties map[interface{}]interface{} `yaml:"properties"`
+}
+
+type Link struct {
+	From   string `yaml:"from"`
+	As     string `yaml:"as"`
+	Shared bool   `yaml:"shared"`
+}
+
+type Network struct {
+	Name      string   `yaml:"name"`
+	StaticIPs []string `yaml:"static_ips"`
+}
+
+type Variable struct {
+	Name    string                      `yaml:"name"`
+	Type    string                      `yaml:"type"`
+	Options map[interface{}]interface{} `yaml:"options"`
+}
+
+// IsV2 reports whether the manifest uses instance_groups rather than jobs.
+func (m MysqlManifest) IsV2() bool {
+	return len(m.InstanceGroups) > 0
+}
+
+// Groups returns the manifest's instance groups. v1 jobs are converted so
+// that callers can treat both manifest shapes the same way.
+func (m MysqlManifest) Groups() []InstanceGroup {
+	if m.IsV2() {
+		return m.InstanceGroups
+	}
+
+	var groups []InstanceGroup
+	for _, job := range m.Jobs {
+		groups = append(groups, InstanceGroup{
+			Instances: job.Instances,
+			Name:      job.Name,
+			Jobs:      job.Templates,
+			Networks:  job.Networks,
+		})
+	}
+
+	return groups
+}
+
+// HasJob reports whether the instance group runs a job with the given name.
+func (g InstanceGroup) HasJob(name string) bool {
+	for _, job := range g.Jobs {
+		if job.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// StaticIPs returns the static IPs of the instance group across all networks.
+func (g InstanceGroup) StaticIPs() []string {
+	var ips []string
+	for _, network := range g.Networks {
+		ips = append(ips, network.StaticIPs...)
+	}
+
+	return ips
 }
 
 type Properties struct {
 	CF struct {
 		APIURL        string   `yaml:"api_url"`
 		AppDomains    []string `yaml:"app_domains"`
+		AppsDomain    string   `yaml:"apps_domain"`
 		AdminUsername string   `yaml:"admin_username"`
 		AdminPassword string   `yaml:"admin_password"`
 		SmokeTests    struct {
@@ -36,7 +125,9 @@ type Properties struct {
 		} `yaml:"smoke_tests"`
 		ExternalHost string `yaml:"external_host"`
 		Broker       struct {
-			Services []struct {
+			AuthUsername string `yaml:"auth_username"`
+			AuthPassword string `yaml:"auth_password"`
+			Services     []struct {
 				Name                      string         `yaml:"name"`
 				MaxUserConnectionsDefault int            `yaml:"max_user_connections_default"`
 				Plans                     []ManifestPlan `yaml:"plans"`
@@ -46,13 +137,16 @@ type Properties struct {
 			APIUsername   string `yaml:"api_username"`
 			APIPassword   string `yaml:"api_password"`
 			APIForceHTTPS bool   `yaml:"api_force_https"`
+			APIPort       int    `yaml:"api_port"`
 		} `yaml:"proxy"`
 	} `yaml:"cf_mysql"`
 }
 
 type ManifestPlan struct {
-	Name               string `yaml:"name"`
-	Private            bool   `yaml:"private"`
-	MaxStorageMB       int    `yaml:"max_storage_mb"`
-	MaxUserConnections int    `yaml:"max_user_connections"`
+	Name               string   `yaml:"name"`
+	Description        string   `yaml:"description"`
+	Bullets            []string `yaml:"bullets"`
+	Private            bool     `yaml:"private"`
+	MaxStorageMB       int      `yaml:"max_storage_mb"`
+	MaxUserConnections int      `yaml:"max_user_connections"`
 }
@@ -0,0 +1,66 @@
+package helpers
+
+import "sort"
+
+// PlanChange is a migration of a service instance from one plan to
+// another of a different storage quota.
+type PlanChange struct {
+	From Plan
+	To   Plan
+}
+
+// PlansUpTo returns the plans whose storage quota the quota specs can fill
+// up without writing more than maxStorageMb, in the configured order.
+func (s Service) PlansUpTo(maxStorageMb int) []Plan {
+	var plans []Plan
+	for _, plan := range s.Plans {
+		if plan.MaxStorageMb <= maxStorageMb {
+			plans = append(plans, plan)
+		}
+	}
+
+	return plans
+}
+
+// Upgrades pairs every plan with the next larger storage quota, smallest
+// first. Plans with the same quota as a smaller plan are skipped, as are
+// changes from plans larger than maxStorageMb, which the specs fill up
+// before upgrading.
+func (s Service) Upgrades(maxStorageMb int) []PlanChange {
+	var changes []PlanChange
+
+	plans := plansBySize(s.Plans)
+	for i := 1; i < len(plans); i++ {
+		from, to := plans[i-1], plans[i]
+		if from.MaxStorageMb == to.MaxStorageMb || from.MaxStorageMb > maxStorageMb {
+			continue
+		}
+
+		changes = append(changes, PlanChange{From: from, To: to})
+	}
+
+	return changes
+}
+
+// Downgrades are the upgrades the other way around. The specs fill up the
+// smaller plan's quota before downgrading, so it must be at most
+// maxStorageMb.
+func (s Service) Downgrades(maxStorageMb int) []PlanChange {
+	var changes []PlanChange
+	for _, upgrade := range s.Upgrades(maxStorageMb) {
+		changes = append(changes, PlanChange{From: upgrade.To, To: upgrade.From})
+	}
+
+	return changes
+}
+
+// plansBySize sorts a copy of the plans by storage quota, keeping the
+// configured order of plans with the same quota.
+func plansBySize(plans []Plan) []Plan {
+	sorted := append([]Plan(nil), plans...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].MaxStorageMb < sorted[j].MaxStorageMb
+	})
+
+	return sorted
+}
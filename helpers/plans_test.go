@@ -0,0 +1,48 @@
+package helpers_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
+)
+
+var _ = Describe("Plan selection", func() {
+	var (
+		small  = helpers.Plan{Name: "10mb", MaxStorageMb: 10}
+		shared = helpers.Plan{Name: "10mb-shared", MaxStorageMb: 10}
+		medium = helpers.Plan{Name: "100mb", MaxStorageMb: 100}
+		large  = helpers.Plan{Name: "1gb", MaxStorageMb: 1024}
+	)
+
+	service := helpers.Service{Name: "p-mysql", Plans: []helpers.Plan{large, small, medium, shared}}
+
+	It("keeps the plans up to the ceiling in the configured order", func() {
+		Expect(service.PlansUpTo(100)).To(Equal([]helpers.Plan{small, medium, shared}))
+		Expect(service.PlansUpTo(5)).To(BeEmpty())
+	})
+
+	It("upgrades each plan to the next larger storage quota", func() {
+		Expect(service.Upgrades(1024)).To(Equal([]helpers.PlanChange{
+			{From: shared, To: medium},
+			{From: medium, To: large},
+		}))
+	})
+
+	It("only upgrades from plans up to the ceiling", func() {
+		Expect(service.Upgrades(10)).To(Equal([]helpers.PlanChange{{From: shared, To: medium}}))
+	})
+
+	It("downgrades to the next smaller storage quota", func() {
+		Expect(service.Downgrades(100)).To(Equal([]helpers.PlanChange{
+			{From: medium, To: shared},
+			{From: large, To: medium},
+		}))
+	})
+
+	It("has nothing to migrate between without two storage quotas", func() {
+		single := helpers.Service{Name: "p-mysql", Plans: []helpers.Plan{small, shared}}
+		Expect(single.Upgrades(1024)).To(BeEmpty())
+		Expect(single.Downgrades(1024)).To(BeEmpty())
+	})
+})
@@ -0,0 +1,164 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const proxyAPIRequestTimeout = 30 * time.Second
+
+// ProxyAPIClient talks to the API of one proxy, at one of the configured
+// dashboard URLs.
+type ProxyAPIClient struct {
+	client   *http.Client
+	url      string
+	username string
+	password string
+}
+
+// ProxyBackend is a MySQL node as the proxy sees it.
+type ProxyBackend struct {
+	Name                string `json:"name"`
+	Host                string `json:"host"`
+	Port                int    `json:"port"`
+	StatusPort          int    `json:"status_port"`
+	Healthy             bool   `json:"healthy"`
+	Enabled             bool   `json:"enabled"`
+	Active              bool   `json:"active"`
+	CurrentSessionCount int    `json:"currentSessionCount"`
+}
+
+// ProxyCluster is the proxy's view of the cluster. ActiveBackend is nil
+// while no backend is healthy.
+type ProxyCluster struct {
+	ActiveBackend       *ProxyBackend `json:"activeBackend"`
+	CurrentBackendIndex int           `json:"currentBackendIndex"`
+	TrafficEnabled      bool          `json:"trafficEnabled"`
+	Message             string        `json:"message"`
+	LastUpdated         time.Time     `json:"lastUpdated"`
+}
+
+func NewProxyAPIClient(proxy Proxy, dashboardURL string) *ProxyAPIClient {
+	return &ProxyAPIClient{
+		client:   newHTTPClient(proxy.SkipSSLValidation, proxyAPIRequestTimeout),
+		url:      strings.TrimSuffix(proxyAPIURL(proxy, dashboardURL), "/"),
+		username: proxy.APIUsername,
+		password: proxy.APIPassword,
+	}
+}
+
+// NewProxyAPIClients returns a client for every configured proxy.
+func NewProxyAPIClients(proxy Proxy) []*ProxyAPIClient {
+	var clients []*ProxyAPIClient
+	for _, dashboardURL := range proxy.DashboardUrls {
+		clients = append(clients, NewProxyAPIClient(proxy, dashboardURL))
+	}
+
+	return clients
+}
+
+func (c *ProxyAPIClient) Cluster() (ProxyCluster, error) {
+	var cluster ProxyCluster
+	return cluster, c.do("GET", "/v0/cluster", &cluster)
+}
+
+func (c *ProxyAPIClient) Backends() ([]ProxyBackend, error) {
+	var backends []ProxyBackend
+	return backends, c.do("GET", "/v0/backends", &backends)
+}
+
+// ActiveBackend returns the backend the proxy routes connections to.
+func (c *ProxyAPIClient) ActiveBackend() (ProxyBackend, error) {
+	cluster, err := c.Cluster()
+	if err != nil {
+		return ProxyBackend{}, err
+	}
+
+	if cluster.ActiveBackend == nil {
+		return ProxyBackend{}, fmt.Errorf("The proxy at %s has no active backend", c.url)
+	}
+
+	return *cluster.ActiveBackend, nil
+}
+
+// DisableTraffic makes the proxy close its connections to the backends and
+// refuse new ones, recording message as the reason.
+func (c *ProxyAPIClient) DisableTraffic(message string) (ProxyCluster, error) {
+	return c.setTrafficEnabled(false, message)
+}
+
+// EnableTraffic lets the proxy route connections to the backends again.
+func (c *ProxyAPIClient) EnableTraffic() (ProxyCluster, error) {
+	return c.setTrafficEnabled(true, "")
+}
+
+// DisableBackend takes one backend out of rotation: the proxy closes its
+// connections to it and routes no new ones there, recording message as the
+// reason.
+func (c *ProxyAPIClient) DisableBackend(name, message string) (ProxyBackend, error) {
+	return c.setBackendEnabled(name, false, message)
+}
+
+// EnableBackend puts the backend back into rotation.
+func (c *ProxyAPIClient) EnableBackend(name string) (ProxyBackend, error) {
+	return c.setBackendEnabled(name, true, "")
+}
+
+func (c *ProxyAPIClient) setBackendEnabled(name string, enabled bool, message string) (ProxyBackend, error) {
+	query := url.Values{"enabled": {strconv.FormatBool(enabled)}}
+	if message != "" {
+		query.Set("message", message)
+	}
+
+	var backend ProxyBackend
+	return backend, c.do("PATCH", "/v0/backends/"+url.PathEscape(name)+"?"+query.Encode(), &backend)
+}
+
+func (c *ProxyAPIClient) setTrafficEnabled(enabled bool, message string) (ProxyCluster, error) {
+	query := url.Values{"trafficEnabled": {strconv.FormatBool(enabled)}}
+	if message != "" {
+		query.Set("message", message)
+	}
+
+	var cluster ProxyCluster
+	return cluster, c.do("PATCH", "/v0/cluster?"+query.Encode(), &cluster)
+}
+
+func (c *ProxyAPIClient) do(method, path string, into interface{}) error {
+	req, err := http.NewRequest(method, c.url+path, nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.username, c.password)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp, http.StatusOK); err != nil {
+		return err
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(into); err != nil {
+		return fmt.Errorf("Decoding %s: %s", path, err.Error())
+	}
+
+	return nil
+}
+
+// proxyAPIURL switches a dashboard URL to https when the proxies redirect
+// plain http requests there, so requests are not redirected.
+func proxyAPIURL(proxy Proxy, dashboardURL string) string {
+	if proxy.APIForceHTTPS && strings.HasPrefix(dashboardURL, "http://") {
+		return "https://" + strings.TrimPrefix(dashboardURL, "http://")
+	}
+
+	return dashboardURL
+}
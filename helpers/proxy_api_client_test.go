@@ -0,0 +1,135 @@
+package helpers_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
+)
+
+var _ = Describe("ProxyAPIClient", func() {
+	var (
+		proxy  *fakeProxy
+		config helpers.Proxy
+	)
+
+	BeforeEach(func() {
+		proxy = newFakeProxy(
+			helpers.ProxyBackend{Name: "mysql-0", Host: "10.244.7.2", Port: 3306, StatusPort: 9200, Healthy: true},
+			helpers.ProxyBackend{Name: "mysql-1", Host: "10.244.8.2", Port: 3306, StatusPort: 9200, Healthy: true},
+		)
+		proxy.activate("mysql-0")
+
+		config = helpers.Proxy{
+			DashboardUrls:     []string{proxy.URL},
+			APIUsername:       fakeProxyUsername,
+			APIPassword:       fakeProxyPassword,
+			SkipSSLValidation: true,
+		}
+	})
+
+	AfterEach(func() {
+		proxy.Close()
+	})
+
+	It("reads the cluster and its backends", func() {
+		client := helpers.NewProxyAPIClients(config)[0]
+
+		cluster, err := client.Cluster()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cluster.TrafficEnabled).To(BeTrue())
+		Expect(cluster.CurrentBackendIndex).To(Equal(0))
+		Expect(cluster.ActiveBackend.Host).To(Equal("10.244.7.2"))
+
+		backends, err := client.Backends()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(backends).To(Equal([]helpers.ProxyBackend{
+			{Name: "mysql-0", Host: "10.244.7.2", Port: 3306, StatusPort: 9200, Healthy: true, Enabled: true, Active: true},
+			{Name: "mysql-1", Host: "10.244.8.2", Port: 3306, StatusPort: 9200, Healthy: true, Enabled: true},
+		}))
+
+		proxy.activate("mysql-1")
+		active, err := client.ActiveBackend()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(active.Name).To(Equal("mysql-1"))
+	})
+
+	It("fails when no backend is active", func() {
+		proxy.activate("")
+
+		_, err := helpers.NewProxyAPIClient(config, proxy.URL).ActiveBackend()
+		Expect(err).To(MatchError("The proxy at " + proxy.URL + " has no active backend"))
+	})
+
+	It("disables and enables traffic to the backends", func() {
+		client := helpers.NewProxyAPIClient(config, proxy.URL+"/")
+
+		cluster, err := client.DisableTraffic("failover test")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cluster.TrafficEnabled).To(BeFalse())
+		Expect(cluster.Message).To(Equal("failover test"))
+
+		cluster, err = client.EnableTraffic()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cluster.TrafficEnabled).To(BeTrue())
+
+		Expect(proxy.requests).To(Equal([]string{
+			"PATCH /v0/cluster?message=failover+test&trafficEnabled=false",
+			"PATCH /v0/cluster?trafficEnabled=true",
+		}))
+	})
+
+	It("takes a single backend out of rotation and puts it back", func() {
+		client := helpers.NewProxyAPIClient(config, proxy.URL)
+
+		backend, err := client.DisableBackend("mysql-1", "failover test")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(backend.Name).To(Equal("mysql-1"))
+		Expect(backend.Enabled).To(BeFalse())
+		Expect(proxy.backendMessage).To(HaveKeyWithValue("mysql-1", "failover test"))
+
+		backends, err := client.Backends()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(backends[0].Enabled).To(BeTrue())
+		Expect(backends[1].Enabled).To(BeFalse())
+
+		backend, err = client.EnableBackend("mysql-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(backend.Enabled).To(BeTrue())
+
+		Expect(proxy.requests).To(ContainElement("PATCH /v0/backends/mysql-1?enabled=false&message=failover+test"))
+		Expect(proxy.requests).To(ContainElement("PATCH /v0/backends/mysql-1?enabled=true"))
+	})
+
+	It("fails to disable a backend the proxy does not know", func() {
+		_, err := helpers.NewProxyAPIClient(config, proxy.URL).DisableBackend("mysql-9", "")
+		Expect(err).To(MatchError(ContainSubstring("returned 404 Not Found")))
+	})
+
+	It("returns the status of a rejected request", func() {
+		config.APIPassword = "wrong"
+
+		_, err := helpers.NewProxyAPIClient(config, proxy.URL).Cluster()
+		Expect(err).To(MatchError(ContainSubstring("returned 401 Unauthorized")))
+	})
+
+	It("validates the proxy's certificate unless told not to", func() {
+		config.SkipSSLValidation = false
+
+		_, err := helpers.NewProxyAPIClient(config, proxy.URL).Cluster()
+		Expect(err).To(MatchError(ContainSubstring("certificate")))
+	})
+
+	It("uses https for plain http dashboard URLs when the API forces https", func() {
+		plainURL := "http://" + strings.TrimPrefix(proxy.URL, "https://")
+
+		_, err := helpers.NewProxyAPIClient(config, plainURL).Cluster()
+		Expect(err).To(HaveOccurred())
+
+		config.APIForceHTTPS = true
+		_, err = helpers.NewProxyAPIClient(config, plainURL).Cluster()
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
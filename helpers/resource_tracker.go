@@ -0,0 +1,96 @@
+package helpers
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Resources tracks the CF resources of the running spec. Suites with a test
+// context delete them after every spec.
+var Resources = &ResourceTracker{}
+
+// ResourceTracker records the apps, service instances, bindings and keys a
+// spec creates, and deletes them newest first however the spec ends.
+// Resources are tracked before they are created, so that one whose creation
+// failed halfway is deleted as well; the cf CLI succeeds at deleting what
+// does not exist.
+type ResourceTracker struct {
+	mu        sync.Mutex
+	resources []trackedResource
+}
+
+type trackedResource struct {
+	description string
+	deleteArgs  []string
+}
+
+// CleanupFailure is a tracked resource that could not be deleted.
+type CleanupFailure struct {
+	Resource string
+	Err      error
+}
+
+func (f CleanupFailure) Error() string {
+	return fmt.Sprintf("Deleting %s: %s", f.Resource, f.Err.Error())
+}
+
+func (t *ResourceTracker) TrackApp(appName string) {
+	t.track("app "+appName, "delete", appName, "-f")
+}
+
+func (t *ResourceTracker) TrackServiceInstance(serviceInstanceName string) {
+	t.track("service instance "+serviceInstanceName, WithWait("delete-service", "-f", serviceInstanceName)...)
+}
+
+func (t *ResourceTracker) TrackBinding(appName, serviceInstanceName string) {
+	t.track(fmt.Sprintf("binding %s -> %s", appName, serviceInstanceName), WithWait("unbind-service", appName, serviceInstanceName)...)
+}
+
+func (t *ResourceTracker) TrackServiceKey(serviceInstanceName, serviceKeyName string) {
+	t.track(fmt.Sprintf("service key %s of %s", serviceKeyName, serviceInstanceName), WithWait("delete-service-key", "-f", serviceInstanceName, serviceKeyName)...)
+}
+
+func (t *ResourceTracker) track(description string, deleteArgs ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.resources = append(t.resources, trackedResource{description: description, deleteArgs: deleteArgs})
+}
+
+// Cleanup deletes every tracked resource in the reverse order they were
+// tracked in and stops tracking them. It carries on past resources that
+// cannot be deleted, giving each cf command up to timeout, and returns
+// them.
+func (t *ResourceTracker) Cleanup(timeout time.Duration) []CleanupFailure {
+	t.mu.Lock()
+	resources := t.resources
+	t.resources = nil
+	t.mu.Unlock()
+
+	var failures []CleanupFailure
+	for i := len(resources) - 1; i >= 0; i-- {
+		resource := resources[i]
+
+		if _, err := RunCf(timeout, resource.deleteArgs...); err != nil {
+			failures = append(failures, CleanupFailure{Resource: resource.description, Err: err})
+		}
+	}
+
+	return failures
+}
+
+// cleanupResources is run after every spec. Resources left behind do not
+// fail the spec: they are printed and listed in the run report instead,
+// where they cannot be mistaken for test failures.
+func cleanupResources() {
+	failures := Resources.Cleanup(TestContext.LongTimeout())
+
+	var messages []string
+	for _, failure := range failures {
+		fmt.Printf("Cleanup failed: %s\n", failure.Error())
+		messages = append(messages, failure.Error())
+	}
+
+	recorder.addCleanupFailures(messages)
+}
@@ -0,0 +1,100 @@
+package helpers_test
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
+)
+
+var _ = Describe("ResourceTracker", func() {
+	var (
+		tracker    *helpers.ResourceTracker
+		originalCf func(args ...string) *gexec.Session
+		cfCommands []string
+		cfScripts  map[string]string
+	)
+
+	BeforeEach(func() {
+		tracker = &helpers.ResourceTracker{}
+
+		// stand in for the cf CLI with a script per command, succeeding by
+		// default
+		cfCommands = nil
+		cfScripts = map[string]string{}
+		originalCf = cf.Cf
+		cf.Cf = func(args ...string) *gexec.Session {
+			cfCommands = append(cfCommands, strings.Join(args, " "))
+
+			script, found := cfScripts[args[0]]
+			if !found {
+				script = "true"
+			}
+
+			session, err := gexec.Start(exec.Command("sh", "-c", script), GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			return session
+		}
+	})
+
+	AfterEach(func() {
+		cf.Cf = originalCf
+	})
+
+	trackAll := func() {
+		tracker.TrackApp("some-app")
+		tracker.TrackServiceInstance("some-instance")
+		tracker.TrackBinding("some-app", "some-instance")
+		tracker.TrackServiceKey("some-instance", "some-key")
+	}
+
+	It("deletes the tracked resources newest first", func() {
+		trackAll()
+
+		Expect(tracker.Cleanup(time.Minute)).To(BeEmpty())
+		Expect(cfCommands).To(Equal([]string{
+			"delete-service-key -f some-instance some-key",
+			"unbind-service some-app some-instance",
+			"delete-service -f some-instance",
+			"delete some-app -f",
+		}))
+	})
+
+	It("forgets the resources it has cleaned up", func() {
+		trackAll()
+		tracker.Cleanup(time.Minute)
+
+		cfCommands = nil
+		Expect(tracker.Cleanup(time.Minute)).To(BeEmpty())
+		Expect(cfCommands).To(BeEmpty())
+	})
+
+	It("carries on past resources that cannot be deleted", func() {
+		cfScripts["unbind-service"] = "echo 'Unbinding app...'; echo 'FAILED' >&2; echo 'Server error' >&2; exit 1"
+		trackAll()
+
+		failures := tracker.Cleanup(time.Minute)
+		Expect(failures).To(HaveLen(1))
+		Expect(failures[0].Resource).To(Equal("binding some-app -> some-instance"))
+		Expect(failures[0].Error()).To(Equal("Deleting binding some-app -> some-instance: cf unbind-service exited with status 1: Server error"))
+		Expect(cfCommands).To(HaveLen(4))
+	})
+
+	It("gives up on commands that do not finish in time", func() {
+		cfScripts["delete-service"] = "exec sleep 10"
+		tracker.TrackServiceInstance("some-instance")
+		tracker.TrackApp("some-app")
+
+		failures := tracker.Cleanup(100 * time.Millisecond)
+		Expect(failures).To(HaveLen(1))
+		Expect(failures[0].Error()).To(Equal(fmt.Sprintf("Deleting service instance some-instance: cf delete-service did not exit within %s", 100*time.Millisecond)))
+		Expect(cfCommands).To(Equal([]string{"delete some-app -f", "delete-service -f some-instance"}))
+	})
+})
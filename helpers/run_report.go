@@ -0,0 +1,355 @@
+package helpers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/types"
+	"github.com/onsi/gomega/gexec"
+)
+
+// RunReportFilePattern names the JSON report each parallel node writes next
+// to its junit_N.xml.
+const RunReportFilePattern = "report_%d.json"
+
+// RunReport is the JSON report of one suite on one parallel node.
+type RunReport struct {
+	Suite             string       `json:"suite"`
+	Node              int          `json:"node"`
+	ConfigFingerprint string       `json:"config_fingerprint"`
+	StartedAt         time.Time    `json:"started_at"`
+	DurationSeconds   float64      `json:"duration_seconds"`
+	Specs             []SpecReport `json:"specs"`
+}
+
+type SpecReport struct {
+	Text            string            `json:"text"`
+	State           string            `json:"state"`
+	StartedAt       time.Time         `json:"started_at"`
+	DurationSeconds float64           `json:"duration_seconds"`
+	Failure         string            `json:"failure,omitempty"`
+	Steps           []StepReport      `json:"steps"`
+	CfCommands      []CfCommandReport `json:"cf_commands"`
+	CleanupFailures []string          `json:"cleanup_failures,omitempty"`
+}
+
+// StepReport is a step declared with Step. A step without a body lasts
+// until the next step or the end of the spec.
+type StepReport struct {
+	Text            string    `json:"text"`
+	StartedAt       time.Time `json:"started_at"`
+	DurationSeconds float64   `json:"duration_seconds"`
+}
+
+// CfCommandReport is a cf invocation made through cf.Cf, with every
+// configured credential redacted from its arguments. ExitCode is nil when
+// the command was still running at the end of the spec.
+type CfCommandReport struct {
+	Args            []string  `json:"args"`
+	StartedAt       time.Time `json:"started_at"`
+	ExitCode        *int      `json:"exit_code"`
+	DurationSeconds float64   `json:"duration_seconds"`
+}
+
+// Step documents a step of a spec like ginkgo's By and records it, with its
+// duration, in the run report.
+func Step(text string, body ...func()) {
+	step := recorder.startStep(text)
+
+	ginkgo.By(text, body...)
+
+	if len(body) > 0 && step != nil {
+		recorder.finishStep(step)
+	}
+}
+
+var recorder = &runRecorder{}
+
+// runRecorder collects the steps and cf commands of the running spec.
+// Commands finish in the background, so everything is guarded by mu.
+type runRecorder struct {
+	mu       sync.Mutex
+	running  bool
+	steps    []*StepReport
+	openStep *StepReport
+	commands []*recordedCommand
+	cleanup  []string
+}
+
+type recordedCommand struct {
+	report  CfCommandReport
+	session *gexec.Session
+	done    chan struct{}
+}
+
+func (r *runRecorder) startSpec() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.running = true
+	r.steps = nil
+	r.openStep = nil
+	r.commands = nil
+	r.cleanup = nil
+}
+
+func (r *runRecorder) finishSpec() ([]StepReport, []CfCommandReport, []string) {
+	// commands that have exited may not have been recorded yet
+	r.mu.Lock()
+	var exited []chan struct{}
+	for _, command := range r.commands {
+		if command.session != nil && command.session.ExitCode() != -1 {
+			exited = append(exited, command.done)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, done := range exited {
+		<-done
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closeOpenStep(time.Now())
+	r.running = false
+
+	steps := []StepReport{}
+	for _, step := range r.steps {
+		steps = append(steps, *step)
+	}
+
+	commands := []CfCommandReport{}
+	for _, command := range r.commands {
+		commands = append(commands, command.report)
+	}
+
+	return steps, commands, r.cleanup
+}
+
+func (r *runRecorder) addCleanupFailures(failures []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.running {
+		r.cleanup = append(r.cleanup, failures...)
+	}
+}
+
+func (r *runRecorder) startStep(text string) *StepReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.running {
+		return nil
+	}
+
+	now := time.Now()
+	r.closeOpenStep(now)
+
+	step := &StepReport{Text: text, StartedAt: now}
+	r.steps = append(r.steps, step)
+	r.openStep = step
+
+	return step
+}
+
+func (r *runRecorder) finishStep(step *StepReport) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	step.DurationSeconds = time.Since(step.StartedAt).Seconds()
+	if r.openStep == step {
+		r.openStep = nil
+	}
+}
+
+func (r *runRecorder) closeOpenStep(now time.Time) {
+	if r.openStep != nil {
+		r.openStep.DurationSeconds = now.Sub(r.openStep.StartedAt).Seconds()
+		r.openStep = nil
+	}
+}
+
+func (r *runRecorder) startCommand(args []string) *recordedCommand {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.running {
+		return nil
+	}
+
+	command := &recordedCommand{
+		report: CfCommandReport{Args: args, StartedAt: time.Now()},
+		done:   make(chan struct{}),
+	}
+	r.commands = append(r.commands, command)
+
+	return command
+}
+
+func (r *runRecorder) watchCommand(command *recordedCommand, session *gexec.Session) {
+	r.mu.Lock()
+	command.session = session
+	r.mu.Unlock()
+
+	go func() {
+		<-session.Exited
+
+		r.mu.Lock()
+		exitCode := session.ExitCode()
+		command.report.ExitCode = &exitCode
+		command.report.DurationSeconds = time.Since(command.report.StartedAt).Seconds()
+		r.mu.Unlock()
+
+		close(command.done)
+	}()
+}
+
+// RecordCfCommands wraps cf.Cf so that every invocation made during a spec
+// ends up in the run report, without the credentials in the config.
+func RecordCfCommands(cfg MysqlIntegrationConfig) error {
+	secrets, err := cfg.secretValues()
+	if err != nil {
+		return err
+	}
+
+	cf.Cf = recordingCf(cf.Cf, secrets)
+	return nil
+}
+
+func recordingCf(run func(args ...string) *gexec.Session, secrets []string) func(args ...string) *gexec.Session {
+	return func(args ...string) *gexec.Session {
+		command := recorder.startCommand(redactArgs(args, secrets))
+
+		session := run(args...)
+		if command != nil {
+			recorder.watchCommand(command, session)
+		}
+
+		return session
+	}
+}
+
+func redactArgs(args []string, secrets []string) []string {
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		for _, secret := range secrets {
+			arg = strings.Replace(arg, secret, redactedValue, -1)
+		}
+
+		redacted[i] = arg
+	}
+
+	return redacted
+}
+
+// configFingerprint identifies the effective config without revealing its
+// credentials, so that reports from different runs can be compared.
+func configFingerprint(cfg MysqlIntegrationConfig) (string, error) {
+	redacted, err := cfg.RedactedJSON()
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(redacted)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// RunReporter is a ginkgo reporter that writes a RunReport to path at the
+// end of the suite.
+type RunReporter struct {
+	path   string
+	report RunReport
+}
+
+func NewRunReporter(path string, cfg MysqlIntegrationConfig) (*RunReporter, error) {
+	fingerprint, err := configFingerprint(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RunReporter{
+		path:   path,
+		report: RunReport{ConfigFingerprint: fingerprint, Specs: []SpecReport{}},
+	}, nil
+}
+
+func (r *RunReporter) SpecSuiteWillBegin(config config.GinkgoConfigType, summary *types.SuiteSummary) {
+	r.report.Suite = summary.SuiteDescription
+	r.report.Node = config.ParallelNode
+	r.report.StartedAt = time.Now()
+}
+
+func (r *RunReporter) BeforeSuiteDidRun(setupSummary *types.SetupSummary) {
+}
+
+func (r *RunReporter) SpecWillRun(specSummary *types.SpecSummary) {
+	recorder.startSpec()
+}
+
+func (r *RunReporter) SpecDidComplete(specSummary *types.SpecSummary) {
+	steps, commands, cleanupFailures := recorder.finishSpec()
+
+	spec := SpecReport{
+		// the first component is ginkgo's "[Top Level]" container
+		Text:            strings.Join(specSummary.ComponentTexts[1:], " "),
+		State:           specState(specSummary.State),
+		StartedAt:       time.Now().Add(-specSummary.RunTime),
+		DurationSeconds: specSummary.RunTime.Seconds(),
+		Steps:           steps,
+		CfCommands:      commands,
+		CleanupFailures: cleanupFailures,
+	}
+
+	if specSummary.Failed() {
+		spec.Failure = fmt.Sprintf("%s\n%s", specSummary.Failure.Message, specSummary.Failure.Location.String())
+	}
+
+	r.report.Specs = append(r.report.Specs, spec)
+}
+
+func (r *RunReporter) AfterSuiteDidRun(setupSummary *types.SetupSummary) {
+}
+
+func (r *RunReporter) SpecSuiteDidEnd(summary *types.SuiteSummary) {
+	r.report.DurationSeconds = summary.RunTime.Seconds()
+
+	buf, err := json.MarshalIndent(r.report, "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(r.path, buf, 0644)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Writing run report: %s\n", err.Error())
+	}
+}
+
+func specState(state types.SpecState) string {
+	switch state {
+	case types.SpecStatePassed:
+		return "passed"
+	case types.SpecStateFailed:
+		return "failed"
+	case types.SpecStatePanicked:
+		return "panicked"
+	case types.SpecStateTimedOut:
+		return "timed out"
+	case types.SpecStateSkipped:
+		return "skipped"
+	case types.SpecStatePending:
+		return "pending"
+	default:
+		return "invalid"
+	}
+}
@@ -0,0 +1,173 @@
+package helpers_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/config"
+	. "github.com/onsi/ginkgo"
+	ginkgoconfig "github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/types"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
+)
+
+var _ = Describe("RunReporter", func() {
+	var (
+		tmpDir     string
+		reportPath string
+		cfg        helpers.MysqlIntegrationConfig
+		originalCf func(args ...string) *gexec.Session
+		cfArgs     [][]string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "run-report")
+		Expect(err).NotTo(HaveOccurred())
+		reportPath = filepath.Join(tmpDir, "report_1.json")
+
+		cfg = helpers.MysqlIntegrationConfig{
+			CFConfig: &config.Config{
+				ApiEndpoint:   "api.bosh-lite.com",
+				AdminUser:     "admin",
+				AdminPassword: "admin-secret",
+			},
+			BrokerPassword: "broker-secret",
+		}
+
+		// stand in for the cf CLI with a command that exits with the
+		// status given as the last argument
+		cfArgs = nil
+		originalCf = cf.Cf
+		cf.Cf = func(args ...string) *gexec.Session {
+			cfArgs = append(cfArgs, args)
+			session, err := gexec.Start(exec.Command("sh", "-c", "exit "+args[len(args)-1]), GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			return session
+		}
+	})
+
+	AfterEach(func() {
+		cf.Cf = originalCf
+		os.RemoveAll(tmpDir)
+	})
+
+	readReport := func() helpers.RunReport {
+		buf, err := ioutil.ReadFile(reportPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		var report helpers.RunReport
+		Expect(json.Unmarshal(buf, &report)).To(Succeed())
+		return report
+	}
+
+	runSuite := func(reporter *helpers.RunReporter, specs ...func() *types.SpecSummary) {
+		reporter.SpecSuiteWillBegin(ginkgoconfig.GinkgoConfigType{ParallelNode: 2}, &types.SuiteSummary{SuiteDescription: "P-MySQL Acceptance Tests -- lifecycle"})
+		for _, spec := range specs {
+			reporter.SpecWillRun(&types.SpecSummary{})
+			reporter.SpecDidComplete(spec())
+		}
+		reporter.SpecSuiteDidEnd(&types.SuiteSummary{RunTime: 3 * time.Second})
+	}
+
+	It("records every spec with its steps and cf commands", func() {
+		Expect(helpers.RecordCfCommands(cfg)).To(Succeed())
+
+		reporter, err := helpers.NewRunReporter(reportPath, cfg)
+		Expect(err).NotTo(HaveOccurred())
+
+		runSuite(reporter, func() *types.SpecSummary {
+			helpers.Step("creating a service instance", func() {
+				Eventually(cf.Cf("create-service-broker", "p-mysql", "broker-user", "broker-secret", "0")).Should(gexec.Exit(0))
+			})
+			helpers.Step("deleting it")
+			Eventually(cf.Cf("delete-service", "-f", "1")).Should(gexec.Exit(1))
+
+			return &types.SpecSummary{
+				ComponentTexts: []string{"[Top Level]", "Lifecycle [p-mysql]", "deletes the instance"},
+				State:          types.SpecStateFailed,
+				RunTime:        2 * time.Second,
+				Failure: types.SpecFailure{
+					Message:  "Expected exit code 0",
+					Location: types.CodeLocation{FileName: "lifecycle_test.go", LineNumber: 42},
+				},
+			}
+		}, func() *types.SpecSummary {
+			return &types.SpecSummary{
+				ComponentTexts: []string{"[Top Level]", "Lifecycle [p-mysql]", "is skipped"},
+				State:          types.SpecStateSkipped,
+			}
+		})
+
+		report := readReport()
+		Expect(report.Suite).To(Equal("P-MySQL Acceptance Tests -- lifecycle"))
+		Expect(report.Node).To(Equal(2))
+		Expect(report.DurationSeconds).To(Equal(3.0))
+		Expect(report.ConfigFingerprint).To(MatchRegexp(`^[0-9a-f]{64}$`))
+		Expect(report.Specs).To(HaveLen(2))
+
+		failed := report.Specs[0]
+		Expect(failed.Text).To(Equal("Lifecycle [p-mysql] deletes the instance"))
+		Expect(failed.State).To(Equal("failed"))
+		Expect(failed.DurationSeconds).To(Equal(2.0))
+		Expect(failed.Failure).To(Equal("Expected exit code 0\nlifecycle_test.go:42"))
+
+		Expect(failed.Steps).To(HaveLen(2))
+		Expect(failed.Steps[0].Text).To(Equal("creating a service instance"))
+		Expect(failed.Steps[1].Text).To(Equal("deleting it"))
+		Expect(failed.Steps[1].StartedAt).NotTo(BeTemporally("<", failed.Steps[0].StartedAt))
+		Expect(failed.Steps[1].DurationSeconds).To(BeNumerically(">", 0))
+
+		Expect(failed.CfCommands).To(HaveLen(2))
+		Expect(failed.CfCommands[0].Args).To(Equal([]string{"create-service-broker", "p-mysql", "broker-user", "[REDACTED]", "0"}))
+		Expect(*failed.CfCommands[0].ExitCode).To(Equal(0))
+		Expect(failed.CfCommands[1].Args).To(Equal([]string{"delete-service", "-f", "1"}))
+		Expect(*failed.CfCommands[1].ExitCode).To(Equal(1))
+		Expect(failed.CfCommands[1].DurationSeconds).To(BeNumerically(">", 0))
+
+		Expect(cfArgs[0]).To(ContainElement("broker-secret"))
+
+		skipped := report.Specs[1]
+		Expect(skipped.State).To(Equal("skipped"))
+		Expect(skipped.Failure).To(BeEmpty())
+		Expect(skipped.Steps).To(BeEmpty())
+		Expect(skipped.CfCommands).To(BeEmpty())
+	})
+
+	It("does not record steps outside of a spec", func() {
+		reporter, err := helpers.NewRunReporter(reportPath, cfg)
+		Expect(err).NotTo(HaveOccurred())
+
+		helpers.Step("before the suite")
+		runSuite(reporter, func() *types.SpecSummary {
+			return &types.SpecSummary{ComponentTexts: []string{"[Top Level]", "passes"}, State: types.SpecStatePassed}
+		})
+
+		Expect(readReport().Specs[0].Steps).To(BeEmpty())
+	})
+
+	It("fingerprints the config without its credentials", func() {
+		fingerprint := func(cfg helpers.MysqlIntegrationConfig) string {
+			reporter, err := helpers.NewRunReporter(reportPath, cfg)
+			Expect(err).NotTo(HaveOccurred())
+			runSuite(reporter)
+			return readReport().ConfigFingerprint
+		}
+
+		original := fingerprint(cfg)
+
+		cfg.CFConfig.AdminPassword = "another-secret"
+		Expect(fingerprint(cfg)).To(Equal(original))
+
+		cfg.BrokerHost = "p-mysql.bosh-lite.com"
+		Expect(fingerprint(cfg)).NotTo(Equal(original))
+	})
+})
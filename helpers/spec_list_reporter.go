@@ -0,0 +1,55 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/types"
+)
+
+// SpecListEnv names the file that a suite appends the full text of every
+// spec it would run to, one per line. `mysql-ats list` sets it for dry runs.
+const SpecListEnv = EnvPrefix + "SPEC_LIST"
+
+type specListReporter struct {
+	path  string
+	specs []string
+}
+
+func newSpecListReporter(path string) *specListReporter {
+	return &specListReporter{path: path}
+}
+
+func (r *specListReporter) SpecSuiteWillBegin(config config.GinkgoConfigType, summary *types.SuiteSummary) {
+}
+
+func (r *specListReporter) BeforeSuiteDidRun(setupSummary *types.SetupSummary) {
+}
+
+func (r *specListReporter) SpecWillRun(specSummary *types.SpecSummary) {
+}
+
+func (r *specListReporter) SpecDidComplete(specSummary *types.SpecSummary) {
+	if specSummary.State == types.SpecStatePassed {
+		// the first component is ginkgo's "[Top Level]" container
+		r.specs = append(r.specs, strings.Join(specSummary.ComponentTexts[1:], " "))
+	}
+}
+
+func (r *specListReporter) AfterSuiteDidRun(setupSummary *types.SetupSummary) {
+}
+
+func (r *specListReporter) SpecSuiteDidEnd(summary *types.SuiteSummary) {
+	f, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Writing spec list: %s\n", err.Error())
+		return
+	}
+	defer f.Close()
+
+	for _, spec := range r.specs {
+		fmt.Fprintln(f, spec)
+	}
+}
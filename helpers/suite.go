@@ -2,43 +2,153 @@ package helpers
 
 import (
 	"fmt"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	ginkgoconfig "github.com/onsi/ginkgo/config"
 	"github.com/onsi/ginkgo/reporters"
 	. "github.com/onsi/gomega"
 
+	"github.com/cloudfoundry-incubator/cf-test-helpers/generator"
 	"github.com/cloudfoundry-incubator/cf-test-helpers/workflowhelpers"
 )
 
 var TestConfig MysqlIntegrationConfig
 var TestContext *workflowhelpers.ReproducibleTestSuiteSetup
 
-func PrepareAndRunTests(packageName string, t *testing.T, withContext bool) {
+var serviceSpecs []func()
+
+// RandomName returns a unique name for a resource a spec creates. It starts
+// with the configured name prefix, so that `mysql-ats sweep` can find the
+// resource if the spec leaks it.
+func RandomName(resource string) string {
+	return generator.PrefixedRandomName(TestConfig.CFConfig.NamePrefix, resource)
+}
+
+// DescribeEachService declares the specs in body once for every service in
+// the config. The config is not loaded yet when the spec files are
+// initialised, so the specs are declared later by PrepareAndRunTests.
+func DescribeEachService(text string, body func(service Service)) bool {
+	serviceSpecs = append(serviceSpecs, func() {
+		for _, service := range TestConfig.AllServices() {
+			service := service
+			Describe(fmt.Sprintf("%s [%s]", text, service.Name), func() {
+				body(service)
+			})
+		}
+	})
+
+	return true
+}
+
+// PrepareAndRunTests loads and validates the integration config before
+// running the suite. Suites list the settings they depend on as
+// requirements; without any, the settings shared by the service suites are
+// validated.
+func PrepareAndRunTests(packageName string, t *testing.T, withContext bool, requirements ...ConfigRequirement) {
 	var err error
 	TestConfig, err = LoadConfig()
 	if err != nil {
-		panic("Loading config: " + err.Error())
+		exitWithConfigError("Loading config", err)
 	}
 
-	err = ValidateConfig(&TestConfig)
+	if len(requirements) > 0 {
+		err = ValidateConfigFor(&TestConfig, requirements...)
+	} else {
+		err = ValidateConfig(&TestConfig)
+	}
 	if err != nil {
-		panic("Validating config: " + err.Error())
+		exitWithConfigError("Validating config", err)
 	}
 
+	if os.Getenv(EnvPrefix+"DEBUG_CONFIG") == "true" {
+		redacted, err := TestConfig.RedactedJSON()
+		if err != nil {
+			exitWithConfigError("Dumping config", err)
+		}
+
+		fmt.Printf("Effective integration config:\n%s\n", redacted)
+	}
+
+	if os.Getenv(PreflightEnv) == "true" {
+		results := Preflight(TestConfig)
+		if !PreflightPassed(results) {
+			fmt.Fprintln(os.Stderr, "Preflight checks failed:")
+			WriteCheckResults(os.Stderr, results)
+			os.Exit(PreflightExitCode)
+		}
+
+		if ginkgoconfig.GinkgoConfig.ParallelNode == 1 {
+			WriteCheckResults(os.Stdout, results)
+		}
+	}
+
+	// listing the specs must never delete anything
+	if value := os.Getenv(SweepEnv); value != "" && ginkgoconfig.GinkgoConfig.ParallelNode == 1 && !ginkgoconfig.GinkgoConfig.DryRun {
+		olderThan, err := time.ParseDuration(value)
+		if err != nil {
+			exitWithConfigError("Parsing $"+SweepEnv, err)
+		}
+
+		// leaked resources should not fail the run, so sweeping is best effort
+		fmt.Printf("Sweeping resources older than %s:\n", olderThan)
+		if err := Sweep(os.Stdout, TestConfig.CFConfig, olderThan, false); err != nil {
+			fmt.Fprintf(os.Stderr, "Sweeping leaked resources failed: %s\n", err.Error())
+		}
+	}
+
+	BeforeEach(resetDiagnostics)
+
 	if withContext {
+		CfMajorVersion, err = DetectCfMajorVersion()
+		if err != nil {
+			exitWithConfigError("Detecting the cf CLI version", err)
+		}
+
 		BeforeEach(func() {
 			TestContext = workflowhelpers.NewTestSuiteSetup(TestConfig.CFConfig)
 			TestContext.Setup()
 		})
 
+		// the tracked resources live in the test context's space, so they
+		// go before it
+		AfterEach(cleanupResources)
+
 		AfterEach(func() {
 			TestContext.Teardown()
 		})
 	}
 
-	RegisterFailHandler(Fail)
-	junitReporter := reporters.NewJUnitReporter(fmt.Sprintf("junit_%d.xml", ginkgoconfig.GinkgoConfig.ParallelNode))
-	RunSpecsWithDefaultAndCustomReporters(t, fmt.Sprintf("P-MySQL Acceptance Tests -- %s", packageName), []Reporter{junitReporter})
+	for _, declareSpecs := range serviceSpecs {
+		declareSpecs()
+	}
+
+	if err := RecordCfCommands(TestConfig); err != nil {
+		exitWithConfigError("Reading credentials", err)
+	}
+
+	RegisterFailHandler(failWithDiagnostics)
+	node := ginkgoconfig.GinkgoConfig.ParallelNode
+	junitReporter := reporters.NewJUnitReporter(fmt.Sprintf("junit_%d.xml", node))
+	runReporter, err := NewRunReporter(fmt.Sprintf(RunReportFilePattern, node), TestConfig)
+	if err != nil {
+		exitWithConfigError("Fingerprinting config", err)
+	}
+	customReporters := []Reporter{junitReporter, runReporter}
+	if path := os.Getenv(SpecListEnv); path != "" {
+		customReporters = append(customReporters, newSpecListReporter(path))
+	}
+
+	RunSpecsWithDefaultAndCustomReporters(t, fmt.Sprintf("P-MySQL Acceptance Tests -- %s", packageName), customReporters)
+}
+
+// exitWithConfigError stops the suite before any spec runs, exiting with
+// ConfigErrorExitCode so wrappers can tell a misconfiguration from failing
+// specs.
+func exitWithConfigError(step string, err error) {
+	fmt.Fprintf(os.Stderr, "%s failed:\n  %s\n", step, strings.Replace(err.Error(), "\n", "\n  ", -1))
+	os.Exit(ConfigErrorExitCode)
 }
@@ -0,0 +1,229 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/config"
+)
+
+// SweepEnv makes the suites delete the resources that earlier runs leaked
+// and that are older than the given duration, e.g. "24h", before any spec
+// runs.
+const SweepEnv = EnvPrefix + "SWEEP_OLDER_THAN"
+
+// LeakedResource is an org, app, service instance, service key or service
+// binding left behind by a run.
+type LeakedResource struct {
+	Kind      string
+	Name      string
+	GUID      string
+	CreatedAt time.Time
+
+	deletePath string
+}
+
+// Sweeper finds and deletes the CF resources whose name starts with the
+// configured name prefix.
+type Sweeper struct {
+	api    *CFAPIClient
+	prefix string
+}
+
+// cfResource holds the fields of the v3 resources the sweeper lists.
+type cfResource struct {
+	GUID          string          `json:"guid"`
+	Name          string          `json:"name"`
+	Type          string          `json:"type"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Relationships CFRelationships `json:"relationships"`
+}
+
+// NewSweeper logs in to the CF API as the admin user.
+func NewSweeper(cfConfig *config.Config) (*Sweeper, error) {
+	api, err := NewCFAPIClientForAdmin(cfConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sweeper{
+		api:    api,
+		prefix: cfConfig.GetNamePrefix() + "-",
+	}, nil
+}
+
+// Find returns the leaked resources that are older than olderThan, in the
+// order they have to be deleted in: service keys and bindings first, then
+// apps, service instances and finally orgs. Keys and bindings of a leaked
+// app or service instance are included whatever their name.
+func (s *Sweeper) Find(olderThan time.Duration) ([]LeakedResource, error) {
+	cutoff := time.Now().Add(-olderThan)
+	isLeaked := func(resource cfResource) bool {
+		return strings.HasPrefix(resource.Name, s.prefix) && resource.CreatedAt.Before(cutoff)
+	}
+
+	find := func(path string) ([]cfResource, error) {
+		all, err := s.list(path)
+		if err != nil {
+			return nil, err
+		}
+
+		var leaked []cfResource
+		for _, resource := range all {
+			if isLeaked(resource) {
+				leaked = append(leaked, resource)
+			}
+		}
+
+		return leaked, nil
+	}
+
+	orgs, err := find("/v3/organizations")
+	if err != nil {
+		return nil, err
+	}
+
+	apps, err := find("/v3/apps")
+	if err != nil {
+		return nil, err
+	}
+
+	instances, err := find("/v3/service_instances")
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]string{}
+	for _, resource := range append(apps, instances...) {
+		names[resource.GUID] = resource.Name
+	}
+
+	// keys and app bindings are both credential bindings in v3
+	allBindings, err := s.list("/v3/service_credential_bindings")
+	if err != nil {
+		return nil, err
+	}
+
+	var keys, bindings []cfResource
+	for _, binding := range allBindings {
+		appGUID := binding.Relationships.GUID("app")
+		instanceGUID := binding.Relationships.GUID("service_instance")
+		appName, leakedApp := names[appGUID]
+		instanceName, leakedInstance := names[instanceGUID]
+
+		if binding.Type == "key" {
+			if leakedInstance || isLeaked(binding) {
+				keys = append(keys, binding)
+			}
+			continue
+		}
+
+		if !leakedApp && !leakedInstance {
+			continue
+		}
+
+		if appName == "" {
+			appName = appGUID
+		}
+		if instanceName == "" {
+			instanceName = instanceGUID
+		}
+		binding.Name = appName + " -> " + instanceName
+		bindings = append(bindings, binding)
+	}
+
+	var leaked []LeakedResource
+	for _, group := range []struct {
+		kind      string
+		path      string
+		resources []cfResource
+	}{
+		{"service key", "/v3/service_credential_bindings", keys},
+		{"service binding", "/v3/service_credential_bindings", bindings},
+		{"app", "/v3/apps", apps},
+		{"service instance", "/v3/service_instances", instances},
+		{"org", "/v3/organizations", orgs},
+	} {
+		resources := group.resources
+		sort.Slice(resources, func(i, j int) bool {
+			return resources[i].Name < resources[j].Name
+		})
+
+		for _, resource := range resources {
+			leaked = append(leaked, LeakedResource{
+				Kind:       group.kind,
+				Name:       resource.Name,
+				GUID:       resource.GUID,
+				CreatedAt:  resource.CreatedAt,
+				deletePath: group.path + "/" + resource.GUID,
+			})
+		}
+	}
+
+	return leaked, nil
+}
+
+// Delete deletes a resource returned by Find and waits for the API to
+// finish deleting it, so that an instance is only deleted once its keys
+// and bindings are gone.
+func (s *Sweeper) Delete(resource LeakedResource) error {
+	return s.api.startJob("DELETE", resource.deletePath, nil)
+}
+
+// Sweep deletes every leaked resource older than olderThan, or only lists
+// them when dryRun is set, and prints one line per resource. It carries on
+// past resources that cannot be deleted and reports how many failed.
+func Sweep(w io.Writer, cfConfig *config.Config, olderThan time.Duration, dryRun bool) error {
+	sweeper, err := NewSweeper(cfConfig)
+	if err != nil {
+		return err
+	}
+
+	leaked, err := sweeper.Find(olderThan)
+	if err != nil {
+		return err
+	}
+
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "KIND\tNAME\tAGE\tRESULT")
+
+	failed := 0
+	for _, resource := range leaked {
+		outcome := "would delete"
+		if !dryRun {
+			outcome = "deleted"
+			if err := sweeper.Delete(resource); err != nil {
+				outcome = "FAIL: " + err.Error()
+				failed++
+			}
+		}
+
+		age := time.Since(resource.CreatedAt).Truncate(time.Minute)
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", resource.Kind, resource.Name, age, outcome)
+	}
+
+	table.Flush()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d leaked resources could not be deleted", failed, len(leaked))
+	}
+
+	return nil
+}
+
+func (s *Sweeper) list(path string) ([]cfResource, error) {
+	var resources []cfResource
+	err := s.api.list(path+"?per_page=100", func(raw json.RawMessage) error {
+		var resource cfResource
+		err := json.Unmarshal(raw, &resource)
+		resources = append(resources, resource)
+		return err
+	})
+
+	return resources, err
+}
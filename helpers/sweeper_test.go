@@ -0,0 +1,206 @@
+package helpers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
+)
+
+var _ = Describe("Sweep", func() {
+	type resource struct {
+		guid, name, kind, appGUID, instanceGUID string
+		age                                     time.Duration
+	}
+
+	var (
+		server       *httptest.Server
+		cfConfig     *config.Config
+		resources    map[string][]resource
+		failDeleting map[string]bool
+		mu           sync.Mutex
+		deleted      []string
+	)
+
+	relationship := func(guid string) interface{} {
+		if guid == "" {
+			return map[string]interface{}{"data": nil}
+		}
+
+		return map[string]interface{}{"data": map[string]string{"guid": guid}}
+	}
+
+	BeforeEach(func() {
+		deleted = nil
+		failDeleting = map[string]bool{}
+		resources = map[string][]resource{
+			"organizations": {
+				{guid: "org-old", name: "MySQLATS-1-ORG-old", age: 48 * time.Hour},
+				{guid: "org-young", name: "MySQLATS-1-ORG-young", age: time.Hour},
+				{guid: "org-other", name: "production", age: 480 * time.Hour},
+			},
+			"apps": {
+				{guid: "app-old", name: "MySQLATS-2-LIFECYCLE-APP-old", age: 30 * time.Hour},
+				{guid: "app-other", name: "lifecycle-app", age: 30 * time.Hour},
+			},
+			"service_instances": {
+				{guid: "instance-old", name: "MySQLATS-2-LIFECYCLE-INSTANCE-old", age: 30 * time.Hour},
+				{guid: "instance-young", name: "MySQLATS-3-QUOTA-INSTANCE-young", age: time.Minute},
+			},
+			"service_credential_bindings": {
+				{guid: "key-of-old-instance", kind: "key", name: "some-key", instanceGUID: "instance-old", age: time.Minute},
+				{guid: "key-other", kind: "key", name: "other-key", instanceGUID: "instance-young", age: 30 * time.Hour},
+				{guid: "binding-old", kind: "app", appGUID: "app-old", instanceGUID: "instance-old", age: 30 * time.Hour},
+				{guid: "binding-other", kind: "app", appGUID: "app-other", instanceGUID: "instance-young", age: 30 * time.Hour},
+			},
+		}
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == "/v2/info":
+				fmt.Fprintf(w, `{"token_endpoint": "%s"}`, server.URL)
+				return
+			case r.URL.Path == "/oauth/token":
+				fmt.Fprint(w, `{"access_token": "admin-token"}`)
+				return
+			case r.Header.Get("Authorization") != "bearer admin-token":
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			case r.URL.Path == "/v3/jobs/delete":
+				fmt.Fprint(w, `{"guid": "delete", "operation": "delete", "state": "COMPLETE"}`)
+				return
+			}
+
+			parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v3/"), "/")
+			if r.Method == "DELETE" {
+				mu.Lock()
+				defer mu.Unlock()
+
+				if failDeleting[parts[1]] {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+
+				deleted = append(deleted, parts[0]+"/"+parts[1])
+				w.Header().Set("Location", server.URL+"/v3/jobs/delete")
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+
+			// serve one resource per page to exercise pagination
+			page := 1
+			fmt.Sscanf(r.URL.Query().Get("page"), "%d", &page)
+
+			listed := resources[parts[0]]
+			response := map[string]interface{}{
+				"pagination": map[string]interface{}{"next": nil},
+				"resources":  []interface{}{},
+			}
+			if page <= len(listed) {
+				res := listed[page-1]
+				response["resources"] = []interface{}{map[string]interface{}{
+					"guid":       res.guid,
+					"name":       res.name,
+					"type":       res.kind,
+					"created_at": time.Now().Add(-res.age).UTC().Format(time.RFC3339),
+					"relationships": map[string]interface{}{
+						"app":              relationship(res.appGUID),
+						"service_instance": relationship(res.instanceGUID),
+					},
+				}}
+			}
+			if page < len(listed) {
+				response["pagination"] = map[string]interface{}{
+					"next": map[string]string{"href": fmt.Sprintf("%s/v3/%s?per_page=1&page=%d", server.URL, parts[0], page+1)},
+				}
+			}
+
+			json.NewEncoder(w).Encode(response)
+		}))
+
+		cfConfig = &config.Config{
+			ApiEndpoint:   server.URL,
+			AdminUser:     "admin",
+			AdminPassword: "admin",
+			NamePrefix:    "MySQLATS",
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("finds the leaked resources in the order they have to be deleted in", func() {
+		sweeper, err := helpers.NewSweeper(cfConfig)
+		Expect(err).NotTo(HaveOccurred())
+
+		leaked, err := sweeper.Find(24 * time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+
+		var found []string
+		for _, resource := range leaked {
+			found = append(found, resource.Kind+" "+resource.Name)
+		}
+
+		Expect(found).To(Equal([]string{
+			"service key some-key",
+			"service binding MySQLATS-2-LIFECYCLE-APP-old -> MySQLATS-2-LIFECYCLE-INSTANCE-old",
+			"app MySQLATS-2-LIFECYCLE-APP-old",
+			"service instance MySQLATS-2-LIFECYCLE-INSTANCE-old",
+			"org MySQLATS-1-ORG-old",
+		}))
+	})
+
+	It("only lists the leaked resources in a dry run", func() {
+		buffer := gbytes.NewBuffer()
+		Expect(helpers.Sweep(buffer, cfConfig, 24*time.Hour, true)).To(Succeed())
+
+		Expect(buffer).To(gbytes.Say(`KIND\s+NAME\s+AGE\s+RESULT\n`))
+		Expect(buffer).To(gbytes.Say(`service key\s+some-key\s+\S+\s+would delete\n`))
+		Expect(buffer).To(gbytes.Say(`org\s+MySQLATS-1-ORG-old\s+4[78]h\d+m0s\s+would delete\n`))
+		Expect(deleted).To(BeEmpty())
+	})
+
+	It("deletes the leaked resources", func() {
+		buffer := gbytes.NewBuffer()
+		Expect(helpers.Sweep(buffer, cfConfig, 24*time.Hour, false)).To(Succeed())
+
+		Expect(deleted).To(Equal([]string{
+			"service_credential_bindings/key-of-old-instance",
+			"service_credential_bindings/binding-old",
+			"apps/app-old",
+			"service_instances/instance-old",
+			"organizations/org-old",
+		}))
+		Expect(buffer).To(gbytes.Say(`app\s+MySQLATS-2-LIFECYCLE-APP-old\s+\S+\s+deleted\n`))
+	})
+
+	It("carries on past resources that cannot be deleted", func() {
+		failDeleting["app-old"] = true
+
+		buffer := gbytes.NewBuffer()
+		err := helpers.Sweep(buffer, cfConfig, 24*time.Hour, false)
+		Expect(err).To(MatchError("1 of 5 leaked resources could not be deleted"))
+
+		Expect(buffer).To(gbytes.Say(`app\s+MySQLATS-2-LIFECYCLE-APP-old\s+\S+\s+FAIL: DELETE /v3/apps/app-old returned 502\n`))
+		Expect(deleted).To(ContainElement("organizations/org-old"))
+	})
+
+	It("only considers resources with the configured prefix", func() {
+		cfConfig.NamePrefix = "CIMySQLATS"
+
+		buffer := gbytes.NewBuffer()
+		Expect(helpers.Sweep(buffer, cfConfig, time.Minute, false)).To(Succeed())
+		Expect(deleted).To(BeEmpty())
+	})
+})
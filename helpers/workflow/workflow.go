@@ -0,0 +1,202 @@
+// Package workflow runs the cf operations the suites share, with the same
+// flags and timeouts everywhere. Everything it creates is tracked in
+// helpers.Resources, so it is deleted after the spec, and registered for
+// the diagnostics collected when the spec fails.
+//
+// A failed cf command is returned as a *helpers.CfCommandError.
+package workflow
+
+import (
+	"time"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/workflowhelpers"
+
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
+)
+
+type App struct {
+	Name string
+	URI  string
+}
+
+// AppOptions are the cf push flags that differ between apps. Path and
+// Manifest are alternatives; fields left empty are not passed.
+type AppOptions struct {
+	Path      string
+	Manifest  string
+	Memory    string
+	Buildpack string
+}
+
+type ServiceInstance struct {
+	Name    string
+	Service string
+	Plan    string
+}
+
+type Binding struct {
+	App             App
+	ServiceInstance ServiceInstance
+}
+
+type ServiceKey struct {
+	Name            string
+	ServiceInstance ServiceInstance
+}
+
+// PushApp pushes the app without starting it, routed on the configured
+// apps domain. The v7 CLI cannot push to a domain, so there the app is
+// pushed without a route and the route is mapped afterwards.
+func PushApp(name string, options AppOptions) (App, error) {
+	app := App{Name: name, URI: helpers.TestConfig.AppURI(name)}
+
+	args := []string{"push", name}
+	for _, flag := range []struct{ name, value string }{
+		{"-m", options.Memory},
+		{"-p", options.Path},
+		{"-f", options.Manifest},
+		{"-b", options.Buildpack},
+	} {
+		if flag.value != "" {
+			args = append(args, flag.name, flag.value)
+		}
+	}
+
+	domain := helpers.TestConfig.CFConfig.AppsDomain
+	if helpers.CfV7() {
+		args = append(args, "--no-route", "--no-start")
+	} else {
+		args = append(args, "-d", domain, "--no-start")
+	}
+
+	helpers.Resources.TrackApp(name)
+	helpers.DiagnoseOnFailure(name, "")
+
+	if err := run(args...); err != nil {
+		return app, err
+	}
+
+	if helpers.CfV7() {
+		return app, run("map-route", name, domain, "--hostname", name)
+	}
+
+	return app, nil
+}
+
+func CreateInstance(service, plan, name string) (ServiceInstance, error) {
+	instance := ServiceInstance{Name: name, Service: service, Plan: plan}
+
+	helpers.Resources.TrackServiceInstance(name)
+	helpers.DiagnoseOnFailure("", name)
+
+	return instance, run(helpers.WithWait("create-service", service, plan, name)...)
+}
+
+// UpdateInstance moves the instance to another plan of its service. The
+// instance is returned unchanged if the update fails.
+func UpdateInstance(instance ServiceInstance, plan string) (ServiceInstance, error) {
+	if err := run(helpers.WithWait("update-service", instance.Name, "-p", plan)...); err != nil {
+		return instance, err
+	}
+
+	instance.Plan = plan
+	return instance, nil
+}
+
+func Bind(app App, instance ServiceInstance) (Binding, error) {
+	binding := Binding{App: app, ServiceInstance: instance}
+
+	helpers.Resources.TrackBinding(app.Name, instance.Name)
+
+	return binding, run(helpers.WithWait("bind-service", app.Name, instance.Name)...)
+}
+
+// StartAndWait starts the app and waits until it answers through pinger.
+func StartAndWait(app App, pinger helpers.Pinger) error {
+	if err := run("start", app.Name); err != nil {
+		return err
+	}
+
+	return pinger.Ping()
+}
+
+// CreateKey creates a service key, passing params with -c unless it is
+// empty.
+func CreateKey(instance ServiceInstance, name, params string) (ServiceKey, error) {
+	key := ServiceKey{Name: name, ServiceInstance: instance}
+
+	args := []string{"create-service-key", instance.Name, name}
+	if params != "" {
+		args = append(args, "-c", params)
+	}
+
+	helpers.Resources.TrackServiceKey(instance.Name, name)
+
+	return key, run(helpers.WithWait(args...)...)
+}
+
+// EnableServiceAccess makes the service's plans available to the org. It
+// runs as the admin user.
+func EnableServiceAccess(service, org string) error {
+	var err error
+	workflowhelpers.AsUser(helpers.TestContext.AdminUserContext(), helpers.TestContext.ShortTimeout(), func() {
+		err = run("enable-service-access", service, "-o", org)
+	})
+
+	return err
+}
+
+// API returns a CF API client acting as the user the cf CLI is logged in
+// as, for specs to assert on the state of what they created.
+func API() (*helpers.CFAPIClient, error) {
+	return helpers.NewCFAPIClientForCLIUser(helpers.TestConfig.CFConfig)
+}
+
+// VisiblePlans lists the plans of the service that the user can see, which
+// are the plans the marketplace offers them.
+func VisiblePlans(service string) ([]helpers.CFServicePlan, error) {
+	api, err := API()
+	if err != nil {
+		return nil, err
+	}
+
+	offerings, err := api.ServiceOfferings(service)
+	if err != nil {
+		return nil, err
+	}
+
+	var plans []helpers.CFServicePlan
+	for _, offering := range offerings {
+		offeringPlans, err := api.ServicePlans(offering.GUID)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, offeringPlans...)
+	}
+
+	return plans, nil
+}
+
+// DashboardURL looks the instance's dashboard URL up in the CF API.
+func DashboardURL(instance ServiceInstance) (string, error) {
+	api, err := API()
+	if err != nil {
+		return "", err
+	}
+
+	found, err := api.FindServiceInstance("", instance.Name)
+	if err != nil {
+		return "", err
+	}
+
+	return found.DashboardURL, nil
+}
+
+func run(args ...string) error {
+	_, err := helpers.RunCf(timeout(), args...)
+	return err
+}
+
+func timeout() time.Duration {
+	return helpers.TestContext.LongTimeout()
+}
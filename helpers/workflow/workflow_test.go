@@ -0,0 +1,256 @@
+package workflow_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/config"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/workflowhelpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers"
+	"github.com/cloudfoundry-incubator/cf-mysql-acceptance-tests/helpers/workflow"
+)
+
+type fakePinger struct {
+	err error
+}
+
+func (p fakePinger) Ping() error {
+	return p.err
+}
+
+var _ = Describe("Workflow", func() {
+	var (
+		originalCf func(args ...string) *gexec.Session
+		cfCommands []string
+		cfScripts  map[string]string
+	)
+
+	BeforeEach(func() {
+		cfConfig := &config.Config{AppsDomain: "bosh-lite.com", TimeoutScale: 1}
+		helpers.TestConfig = helpers.MysqlIntegrationConfig{CFConfig: cfConfig}
+		helpers.TestContext = workflowhelpers.NewTestSuiteSetup(cfConfig)
+
+		// stand in for the cf CLI with a script per command, succeeding by
+		// default
+		cfCommands = nil
+		cfScripts = map[string]string{}
+		originalCf = cf.Cf
+		cf.Cf = func(args ...string) *gexec.Session {
+			cfCommands = append(cfCommands, strings.Join(args, " "))
+
+			script, found := cfScripts[args[0]]
+			if !found {
+				script = "true"
+			}
+
+			session, err := gexec.Start(exec.Command("sh", "-c", script), GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			return session
+		}
+	})
+
+	AfterEach(func() {
+		helpers.Resources.Cleanup(time.Minute)
+		helpers.CfMajorVersion = 0
+		cf.Cf = originalCf
+	})
+
+	cleanupCommands := func() []string {
+		cfCommands = nil
+		Expect(helpers.Resources.Cleanup(time.Minute)).To(BeEmpty())
+		return cfCommands
+	}
+
+	It("pushes an app without starting it, on the apps domain", func() {
+		app, err := workflow.PushApp("some-app", workflow.AppOptions{Memory: "256M", Path: "assets/app", Buildpack: "ruby_buildpack"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(app).To(Equal(workflow.App{Name: "some-app", URI: "https://some-app.bosh-lite.com"}))
+
+		_, err = workflow.PushApp("other-app", workflow.AppOptions{Manifest: "assets/manifest.yml"})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfCommands).To(Equal([]string{
+			"push some-app -m 256M -p assets/app -b ruby_buildpack -d bosh-lite.com --no-start",
+			"push other-app -f assets/manifest.yml -d bosh-lite.com --no-start",
+		}))
+	})
+
+	It("creates, binds and starts, tracking what it creates", func() {
+		app, err := workflow.PushApp("some-app", workflow.AppOptions{Path: "assets/app"})
+		Expect(err).NotTo(HaveOccurred())
+
+		instance, err := workflow.CreateInstance("p-mysql", "10mb", "some-instance")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(instance).To(Equal(workflow.ServiceInstance{Name: "some-instance", Service: "p-mysql", Plan: "10mb"}))
+
+		binding, err := workflow.Bind(app, instance)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(binding).To(Equal(workflow.Binding{App: app, ServiceInstance: instance}))
+
+		key, err := workflow.CreateKey(instance, "some-key", `{"read-only":true}`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(key).To(Equal(workflow.ServiceKey{Name: "some-key", ServiceInstance: instance}))
+
+		Expect(workflow.StartAndWait(app, fakePinger{})).To(Succeed())
+
+		Expect(cfCommands[1:]).To(Equal([]string{
+			"create-service p-mysql 10mb some-instance",
+			"bind-service some-app some-instance",
+			`create-service-key some-instance some-key -c {"read-only":true}`,
+			"start some-app",
+		}))
+
+		Expect(cleanupCommands()).To(Equal([]string{
+			"delete-service-key -f some-instance some-key",
+			"unbind-service some-app some-instance",
+			"delete-service -f some-instance",
+			"delete some-app -f",
+		}))
+	})
+
+	It("tracks resources whose creation failed", func() {
+		cfScripts["create-service"] = "echo 'FAILED' >&2; echo 'Service broker error: no capacity' >&2; exit 1"
+
+		instance, err := workflow.CreateInstance("p-mysql", "10mb", "some-instance")
+		Expect(err).To(MatchError("cf create-service exited with status 1: Service broker error: no capacity"))
+		Expect(instance.Name).To(Equal("some-instance"))
+
+		Expect(cleanupCommands()).To(Equal([]string{"delete-service -f some-instance"}))
+	})
+
+	It("returns the cf output of a failed update and keeps the plan", func() {
+		cfScripts["update-service"] = "echo 'Updating service instance...'; echo 'Server error, status code: 502, Service broker error: over quota'; exit 1"
+		instance := workflow.ServiceInstance{Name: "some-instance", Service: "p-mysql", Plan: "100mb"}
+
+		updated, err := workflow.UpdateInstance(instance, "10mb")
+		Expect(updated).To(Equal(instance))
+		Expect(err).To(BeAssignableToTypeOf(&helpers.CfCommandError{}))
+		Expect(err.(*helpers.CfCommandError).ExitCode).To(Equal(1))
+		Expect(err.(*helpers.CfCommandError).Output).To(ContainSubstring("Service broker error"))
+
+		delete(cfScripts, "update-service")
+		updated, err = workflow.UpdateInstance(instance, "10mb")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated.Plan).To(Equal("10mb"))
+	})
+
+	It("does not ping an app that did not start", func() {
+		cfScripts["start"] = "exit 1"
+
+		err := workflow.StartAndWait(workflow.App{Name: "some-app"}, fakePinger{err: errors.New("should not be pinged")})
+		Expect(err).To(MatchError(ContainSubstring("cf start exited with status 1")))
+
+		delete(cfScripts, "start")
+		Expect(workflow.StartAndWait(workflow.App{Name: "other-app"}, fakePinger{err: errors.New("not running")})).To(MatchError("not running"))
+	})
+
+	It("only passes key parameters when there are some", func() {
+		_, err := workflow.CreateKey(workflow.ServiceInstance{Name: "some-instance"}, "some-key", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfCommands).To(Equal([]string{"create-service-key some-instance some-key"}))
+	})
+
+	Describe("looking resources up in the CF API", func() {
+		var server *httptest.Server
+
+		BeforeEach(func() {
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "bearer user-token" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+
+				switch r.URL.RequestURI() {
+				case "/v3/service_offerings?names=p-mysql":
+					fmt.Fprint(w, `{"resources": [{"guid": "offering-1", "name": "p-mysql"}]}`)
+				case "/v3/service_plans?service_offering_guids=offering-1":
+					fmt.Fprint(w, `{"resources": [{"guid": "plan-1", "name": "10mb"}, {"guid": "plan-2", "name": "100mb"}]}`)
+				case "/v3/service_instances?names=some+instance":
+					fmt.Fprint(w, `{"resources": [{"name": "some instance", "dashboard_url": "https://p-mysql.bosh-lite.com/manage/instances/1"}]}`)
+				default:
+					fmt.Fprint(w, `{"resources": []}`)
+				}
+			}))
+
+			helpers.TestConfig.CFConfig.ApiEndpoint = server.URL
+			cfScripts["oauth-token"] = "echo 'bearer user-token'"
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("lists the plans of the service the user can see", func() {
+			plans, err := workflow.VisiblePlans("p-mysql")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plans).To(HaveLen(2))
+			Expect(plans[0].Name).To(Equal("10mb"))
+			Expect(plans[1].Name).To(Equal("100mb"))
+
+			plans, err = workflow.VisiblePlans("other")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plans).To(BeEmpty())
+		})
+
+		It("looks up the dashboard URL", func() {
+			dashboardURL, err := workflow.DashboardURL(workflow.ServiceInstance{Name: "some instance"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dashboardURL).To(Equal("https://p-mysql.bosh-lite.com/manage/instances/1"))
+		})
+
+		It("fails to look up the dashboard URL of an unknown instance", func() {
+			_, err := workflow.DashboardURL(workflow.ServiceInstance{Name: "some-instance"})
+			Expect(err).To(MatchError("No service instance named some-instance"))
+		})
+	})
+
+	Context("with the v7 or a later cf CLI", func() {
+		BeforeEach(func() {
+			helpers.CfMajorVersion = 8
+		})
+
+		It("pushes without a route and maps one on the apps domain", func() {
+			_, err := workflow.PushApp("some-app", workflow.AppOptions{Memory: "256M", Path: "assets/app"})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(cfCommands).To(Equal([]string{
+				"push some-app -m 256M -p assets/app --no-route --no-start",
+				"map-route some-app bosh-lite.com --hostname some-app",
+			}))
+		})
+
+		It("waits for the broker in service operations", func() {
+			app := workflow.App{Name: "some-app"}
+			instance, err := workflow.CreateInstance("p-mysql", "10mb", "some-instance")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = workflow.Bind(app, instance)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = workflow.UpdateInstance(instance, "100mb")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = workflow.CreateKey(instance, "some-key", "")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(cfCommands).To(Equal([]string{
+				"create-service p-mysql 10mb some-instance --wait",
+				"bind-service some-app some-instance --wait",
+				"update-service some-instance -p 100mb --wait",
+				"create-service-key some-instance some-key --wait",
+			}))
+			Expect(cleanupCommands()).To(Equal([]string{
+				"delete-service-key -f some-instance some-key --wait",
+				"unbind-service some-app some-instance --wait",
+				"delete-service -f some-instance --wait",
+			}))
+		})
+	})
+})